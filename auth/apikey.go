@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyPrefix starts every API key, so keys are easy to spot in logs and secret scanners.
+const APIKeyPrefix = "apd_"
+
+// NewAPIKey returns a fresh key of the form apd_<prefix>_<secret>, its public prefix and the hash to store.
+// The full key is only ever shown to the caller once.
+func NewAPIKey() (key, prefix, hash string, err error) {
+	buf := make([]byte, 28)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	prefix = hex.EncodeToString(buf[:4])
+	key = APIKeyPrefix + prefix + "_" + hex.EncodeToString(buf[4:])
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// ParseAPIKey returns the public prefix of key, or ok=false if key is not shaped like an API key.
+func ParseAPIKey(key string) (prefix string, ok bool) {
+	rest, found := strings.CutPrefix(key, APIKeyPrefix)
+	if !found {
+		return "", false
+	}
+	prefix, secret, found := strings.Cut(rest, "_")
+	if !found || len(prefix) != 8 || secret == "" {
+		return "", false
+	}
+	return prefix, true
+}
+
+func HashAPIKey(key string) string { return hashToken(key) }
+
+// hashToken is used for high-entropy secrets (API keys, refresh tokens), where a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyMatches compares key against a stored hash in constant time.
+func APIKeyMatches(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(key)), []byte(hash)) == 1
+}
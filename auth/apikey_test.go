@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewAPIKeyRoundTrip(t *testing.T) {
+	key, prefix, hash, err := NewAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, APIKeyPrefix+prefix+"_") {
+		t.Fatalf("key %q does not start with its prefix %q", key, prefix)
+	}
+	if got, ok := ParseAPIKey(key); !ok || got != prefix {
+		t.Errorf("ParseAPIKey: got %q, %v", got, ok)
+	}
+	if !APIKeyMatches(key, hash) {
+		t.Error("key does not match its own hash")
+	}
+	if APIKeyMatches(key+"x", hash) {
+		t.Error("tampered key matched")
+	}
+	for _, bad := range []string{"", "apd_", "apd_short_secret", "xyz_12345678_secret", "apd_12345678_"} {
+		if _, ok := ParseAPIKey(bad); ok {
+			t.Errorf("ParseAPIKey(%q) accepted a malformed key", bad)
+		}
+	}
+}
@@ -14,6 +14,11 @@ func CookieName() string {
 	return name
 }
 
+// RefreshCookieName holds the refresh token next to the access token cookie.
+func RefreshCookieName() string {
+	return CookieName() + "_refresh"
+}
+
 func ExtractCookieDomain(host string) string {
 	if idx := strings.Index(host, ":"); idx != -1 {
 		host = host[:idx]
@@ -21,28 +26,53 @@ func ExtractCookieDomain(host string) string {
 	return host
 }
 
+// SetSessionCookie stores the access token; it expires together with the token.
 func SetSessionCookie(w http.ResponseWriter, token string, host string, secure bool) {
-	domain := ExtractCookieDomain(host)
-	http.SetCookie(w, &http.Cookie{
-		Name:     CookieName(),
-		Value:    token,
-		Path:     "/",
-		MaxAge:   86400, // 24h
-		HttpOnly: true,
-		Secure:   secure,
-		SameSite: http.SameSiteLaxMode,
-		Domain:   domain,
-	})
+	setCookie(w, CookieName(), token, int(AccessTokenDuration.Seconds()), host, secure, true)
+}
+
+// SetRefreshCookie stores the refresh token.
+func SetRefreshCookie(w http.ResponseWriter, token string, host string, secure bool) {
+	setCookie(w, RefreshCookieName(), token, int(RefreshTokenDuration.Seconds()), host, secure, true)
 }
 
+// SetCSRFCookie stores the CSRF token without HttpOnly so the admin app can copy it into CSRFHeader.
+// It lives as long as the access token it is bound to.
+func SetCSRFCookie(w http.ResponseWriter, token string, host string, secure bool) {
+	setCookie(w, CSRFCookieName(), token, int(AccessTokenDuration.Seconds()), host, secure, false)
+}
+
+// OIDCStateCookieName holds the signed OIDCState of a single sign-on login in progress.
+func OIDCStateCookieName() string {
+	return CookieName() + "_oidc"
+}
+
+// SetOIDCStateCookie stores the state between the redirect to the identity provider and the callback.
+// SameSite=Lax still sends it on the provider's top-level redirect back.
+func SetOIDCStateCookie(w http.ResponseWriter, token string, host string, secure bool) {
+	setCookie(w, OIDCStateCookieName(), token, int(OIDCStateDuration.Seconds()), host, secure, true)
+}
+
+// ClearOIDCStateCookie makes a state usable for a single callback.
+func ClearOIDCStateCookie(w http.ResponseWriter, host string, secure bool) {
+	setCookie(w, OIDCStateCookieName(), "", -1, host, secure, true)
+}
+
+// ClearSessionCookie removes the access, refresh and CSRF cookies.
 func ClearSessionCookie(w http.ResponseWriter, host string, secure bool) {
+	setCookie(w, CookieName(), "", -1, host, secure, true)
+	setCookie(w, RefreshCookieName(), "", -1, host, secure, true)
+	setCookie(w, CSRFCookieName(), "", -1, host, secure, false)
+}
+
+func setCookie(w http.ResponseWriter, name, value string, maxAge int, host string, secure, httpOnly bool) {
 	domain := ExtractCookieDomain(host)
 	http.SetCookie(w, &http.Cookie{
-		Name:     CookieName(),
-		Value:    "",
+		Name:     name,
+		Value:    value,
 		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
+		MaxAge:   maxAge,
+		HttpOnly: httpOnly,
 		Secure:   secure,
 		SameSite: http.SameSiteLaxMode,
 		Domain:   domain,
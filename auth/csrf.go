@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// CSRFHeader carries the CSRF token on unsafe cookie-authenticated requests.
+const CSRFHeader = "X-CSRF-Token"
+
+// CSRFCookieName is readable by scripts on the admin origin, which echo its value in CSRFHeader.
+func CSRFCookieName() string {
+	return CookieName() + "_csrf"
+}
+
+// NewCSRFToken returns a token for the client and the hash the access token carries, binding the
+// token to the session: a cookie planted from another origin cannot match the victim's access token.
+func NewCSRFToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+// CSRFTokenMatches compares token against the hash from the access token in constant time.
+func CSRFTokenMatches(token, hash string) bool {
+	if token == "" || hash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(hash)) == 1
+}
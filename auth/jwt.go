@@ -2,56 +2,92 @@ package auth
 
 import (
 	"errors"
-	"log"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
-const DefaultTokenDuration = 24 * time.Hour
+// AccessTokenDuration is kept short because access tokens are only revocable through the revocation list;
+// clients renew them with the refresh token.
+const AccessTokenDuration = 15 * time.Minute
 
+// Claims identify the user and the server-side session the access token belongs to.
+// RegisteredClaims.ID is the token's jti, checked against the revocation list on every request.
+// CSRFHash binds the CSRF token issued with the access token (see NewCSRFToken).
 type Claims struct {
-	BrandID uuid.UUID `json:"brand_id"`
-	UserID  string    `json:"user_id"`
+	BrandID   uuid.UUID `json:"brand_id"`
+	UserID    string    `json:"user_id"`
+	SessionID uuid.UUID `json:"sid"`
+	CSRFHash  string    `json:"csrf,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func getSecretKey() string {
-	s := os.Getenv("JWT_SECRET")
-	if s == "" {
-		log.Fatal("JWT_SECRET is not set")
-	}
-	return s
-}
-
-func CreateToken(brandID uuid.UUID, userID string, duration time.Duration) (string, error) {
+// CreateToken signs an access token for the session and returns it together with its claims.
+// csrfHash may be empty for tokens that are never sent as cookies.
+func CreateToken(brandID uuid.UUID, userID string, sessionID uuid.UUID, csrfHash string, duration time.Duration) (string, *Claims, error) {
 	if duration == 0 {
-		duration = DefaultTokenDuration
+		duration = AccessTokenDuration
 	}
 	now := time.Now()
 	claims := &Claims{
-		BrandID: brandID,
-		UserID: userID,
+		BrandID:   brandID,
+		UserID:    userID,
+		SessionID: sessionID,
+		CSRFHash:  csrfHash,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(getSecretKey()))
+	signed, err := Keys().sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
 }
 
 func ParseAndValidate(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(getSecretKey()), nil
-	})
+	token, err := Keys().parse(tokenString, &Claims{})
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid || claims.ID == "" || claims.SessionID == uuid.Nil {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// loginChallengeAudience marks tokens that only prove the password step of a two-factor login.
+const loginChallengeAudience = "login-2fa"
+
+// LoginChallengeDuration bounds how long the user has to enter their second factor.
+const LoginChallengeDuration = 5 * time.Minute
+
+// CreateLoginChallenge signs a token saying userID passed the password check. It is not a session:
+// RequireAuth rejects it because it carries no session ID.
+func CreateLoginChallenge(brandID uuid.UUID, userID string) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		BrandID: brandID,
+		UserID:  userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Audience:  jwt.ClaimStrings{loginChallengeAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(LoginChallengeDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	return Keys().sign(claims)
+}
+
+func ParseLoginChallenge(tokenString string) (*Claims, error) {
+	token, err := Keys().parse(tokenString, &Claims{}, jwt.WithAudience(loginChallengeAudience))
 	if err != nil {
 		return nil, err
 	}
@@ -61,3 +97,42 @@ func ParseAndValidate(tokenString string) (*Claims, error) {
 	}
 	return claims, nil
 }
+
+// oidcStateAudience marks tokens that carry an in-progress OpenID Connect login between redirect and callback.
+const oidcStateAudience = "oidc-login"
+
+// OIDCStateDuration bounds how long the user has to sign in at the identity provider.
+const OIDCStateDuration = 10 * time.Minute
+
+// OIDCState is what the callback needs to finish a login started by this browser: the state and nonce
+// sent to the provider and the PKCE verifier for the code exchange.
+type OIDCState struct {
+	BrandID  uuid.UUID `json:"brand_id"`
+	State    string    `json:"state"`
+	Nonce    string    `json:"nonce"`
+	Verifier string    `json:"verifier"`
+	jwt.RegisteredClaims
+}
+
+// CreateOIDCState signs s for the SSO state cookie.
+func CreateOIDCState(s OIDCState) (string, error) {
+	now := time.Now()
+	s.RegisteredClaims = jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{oidcStateAudience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(OIDCStateDuration)),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+	return Keys().sign(&s)
+}
+
+func ParseOIDCState(tokenString string) (*OIDCState, error) {
+	token, err := Keys().parse(tokenString, &OIDCState{}, jwt.WithAudience(oidcStateAudience))
+	if err != nil {
+		return nil, err
+	}
+	s, ok := token.Claims.(*OIDCState)
+	if !ok || !token.Valid || s.State == "" {
+		return nil, errors.New("invalid token")
+	}
+	return s, nil
+}
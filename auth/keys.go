@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms.
+const (
+	AlgEdDSA = "EdDSA"
+	AlgRS256 = "RS256"
+)
+
+// minRSABits rejects RSA keys too small to sign tokens safely.
+const minRSABits = 2048
+
+// Key is one JWT signing key. Private is nil for retired keys kept only to verify tokens they signed.
+type Key struct {
+	ID      string
+	Alg     string
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	if k.Alg == AlgRS256 {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodEdDSA
+}
+
+// KeySet signs tokens with its active key and verifies them with any key, selected by the token's kid header.
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+	ids    []string
+}
+
+// NewKeySet builds a key set that signs with activeID. An empty activeID picks the last private key in ID order,
+// so date-prefixed IDs from GenerateKey make the newest key active.
+func NewKeySet(keys []*Key, activeID string) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key, len(keys))}
+	for _, k := range keys {
+		if k.ID == "" {
+			return nil, errors.New("key without an ID")
+		}
+		if _, dup := ks.keys[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate key ID %q", k.ID)
+		}
+		ks.keys[k.ID] = k
+		ks.ids = append(ks.ids, k.ID)
+	}
+	sort.Strings(ks.ids)
+	if activeID == "" {
+		for _, id := range ks.ids {
+			if ks.keys[id].Private != nil {
+				activeID = id
+			}
+		}
+	}
+	active, ok := ks.keys[activeID]
+	if !ok || active.Private == nil {
+		return nil, fmt.Errorf("no private key for active key ID %q", activeID)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+// ActiveKeyID is the kid new tokens are signed with.
+func (ks *KeySet) ActiveKeyID() string { return ks.active.ID }
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.method(), claims)
+	token.Header["kid"] = ks.active.ID
+	return token.SignedString(ks.active.Private)
+}
+
+// keyFunc resolves the verification key from the kid header and refuses a token whose alg does not match that key,
+// so a public key can never be used as an HMAC secret.
+func (ks *KeySet) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	if t.Method.Alg() != k.Alg {
+		return nil, errors.New("unexpected signing method")
+	}
+	return k.Public, nil
+}
+
+func (ks *KeySet) parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	opts = append(opts, jwt.WithValidMethods([]string{AlgEdDSA, AlgRS256}))
+	return jwt.ParseWithClaims(tokenString, claims, ks.keyFunc, opts...)
+}
+
+// JWK is the public half of a key in RFC 7517 form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists every verification key, including retired ones, so tokens they signed stay verifiable elsewhere.
+func (ks *KeySet) JWKS() JWKS {
+	doc := JWKS{Keys: make([]JWK, 0, len(ks.ids))}
+	b64 := base64.RawURLEncoding
+	for _, id := range ks.ids {
+		k := ks.keys[id]
+		jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Alg}
+		switch pub := k.Public.(type) {
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv, jwk.X = "OKP", "Ed25519", b64.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = b64.EncodeToString(pub.N.Bytes())
+			jwk.E = b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		}
+		doc.Keys = append(doc.Keys, jwk)
+	}
+	return doc
+}
+
+// GenerateKey creates a key for alg with an ID of the form YYYYMMDD-<hex>, which sorts by creation date.
+func GenerateKey(alg string) (*Key, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	k := &Key{ID: time.Now().UTC().Format("20060102") + "-" + hex.EncodeToString(suffix), Alg: alg}
+	switch alg {
+	case AlgEdDSA:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		k.Private, k.Public = priv, pub
+	case AlgRS256:
+		priv, err := rsa.GenerateKey(rand.Reader, minRSABits)
+		if err != nil {
+			return nil, err
+		}
+		k.Private, k.Public = priv, &priv.PublicKey
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	return k, nil
+}
+
+// WriteKey saves k's private key as <dir>/<kid>.pem (PKCS #8) and returns the path.
+func WriteKey(dir string, k *Key) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, k.ID+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return path, os.WriteFile(path, data, 0o600)
+}
+
+// LoadKeySet reads every *.pem file in dir; the file name without .pem is the kid. PRIVATE KEY blocks can sign,
+// PUBLIC KEY blocks (retired keys) only verify. activeID selects the signing key as in NewKeySet.
+func LoadKeySet(dir, activeID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no *.pem keys in %s", dir)
+	}
+	keys := make([]*Key, 0, len(paths))
+	for _, path := range paths {
+		k, err := readKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, k)
+	}
+	return NewKeySet(keys, activeID)
+}
+
+func readKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	k := &Key{ID: strings.TrimSuffix(filepath.Base(path), ".pem")}
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch key := parsed.(type) {
+	case ed25519.PrivateKey:
+		k.Alg, k.Private, k.Public = AlgEdDSA, key, key.Public()
+	case ed25519.PublicKey:
+		k.Alg, k.Public = AlgEdDSA, key
+	case *rsa.PrivateKey:
+		k.Alg, k.Private, k.Public = AlgRS256, key, &key.PublicKey
+	case *rsa.PublicKey:
+		k.Alg, k.Public = AlgRS256, key
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	if pub, ok := k.Public.(*rsa.PublicKey); ok && pub.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("RSA key is %d bits; at least %d are required", pub.N.BitLen(), minRSABits)
+	}
+	return k, nil
+}
+
+var (
+	keysMu sync.RWMutex
+	keys   *KeySet
+)
+
+// SetKeys installs the key set used to sign and verify tokens. Call it once at startup.
+func SetKeys(ks *KeySet) {
+	keysMu.Lock()
+	keys = ks
+	keysMu.Unlock()
+}
+
+// EphemeralKeySet returns a key set with one new Ed25519 key held only in memory. Tokens it signs stop
+// verifying when the process exits and no other instance can verify them, so it is for development and tests.
+func EphemeralKeySet() (*KeySet, error) {
+	k, err := GenerateKey(AlgEdDSA)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet([]*Key{k}, k.ID)
+}
+
+// Keys returns the key set installed with SetKeys. Signing with no keys installed is a startup bug, so it panics.
+func Keys() *KeySet {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	if keys == nil {
+		panic("auth: no JWT signing keys installed; call SetKeys at startup")
+	}
+	return keys
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func withKeys(t *testing.T, ks *KeySet) {
+	t.Helper()
+	keysMu.RLock()
+	prev := keys
+	keysMu.RUnlock()
+	SetKeys(ks)
+	t.Cleanup(func() { SetKeys(prev) })
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, _ := GenerateKey(AlgEdDSA)
+	newKey, _ := GenerateKey(AlgRS256)
+	oldSet, _ := NewKeySet([]*Key{oldKey}, "")
+	withKeys(t, oldSet)
+	token, _, err := CreateToken(uuid.New(), "u1", uuid.New(), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Step 2 of a rotation: the new key signs, the old one still verifies.
+	rotated, err := NewKeySet([]*Key{oldKey, newKey}, newKey.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeys(rotated)
+	if _, err := ParseAndValidate(token); err != nil {
+		t.Errorf("token from the previous key rejected: %v", err)
+	}
+	fresh, _, _ := CreateToken(uuid.New(), "u1", uuid.New(), "", 0)
+	if tok, _, _ := jwt.NewParser().ParseUnverified(fresh, &Claims{}); tok.Header["kid"] != newKey.ID || tok.Method.Alg() != AlgRS256 {
+		t.Errorf("new token header: %v", tok.Header)
+	}
+	if got := len(rotated.JWKS().Keys); got != 2 {
+		t.Errorf("JWKS: got %d keys, want 2", got)
+	}
+
+	// Once the old key is dropped its tokens no longer verify.
+	newOnly, _ := NewKeySet([]*Key{newKey}, "")
+	SetKeys(newOnly)
+	if _, err := ParseAndValidate(token); err == nil {
+		t.Error("token from a removed key accepted")
+	}
+}
+
+func TestParseRejectsHMACWithPublicKey(t *testing.T) {
+	k, _ := GenerateKey(AlgEdDSA)
+	ks, _ := NewKeySet([]*Key{k}, "")
+	withKeys(t, ks)
+	claims := &Claims{UserID: "u1", SessionID: uuid.New(), RegisteredClaims: jwt.RegisteredClaims{ID: "x"}}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = k.ID
+	s, _ := forged.SignedString([]byte(ks.JWKS().Keys[0].X))
+	if _, err := ParseAndValidate(s); err == nil {
+		t.Error("HS256 token accepted")
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+	retired, _ := GenerateKey(AlgEdDSA)
+	der, _ := x509.MarshalPKIXPublicKey(retired.Public)
+	os.WriteFile(filepath.Join(dir, retired.ID+".pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600)
+	active, _ := GenerateKey(AlgEdDSA)
+	active.ID = "zz-" + active.ID
+	if _, err := WriteKey(dir, active); err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ks.ActiveKeyID() != active.ID || len(ks.JWKS().Keys) != 2 {
+		t.Errorf("active %q, %d keys", ks.ActiveKeyID(), len(ks.JWKS().Keys))
+	}
+	if _, err := LoadKeySet(dir, retired.ID); err == nil {
+		t.Error("public-only key accepted as the signing key")
+	}
+}
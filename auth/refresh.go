@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshTokenDuration is how long a session stays alive without being refreshed.
+const RefreshTokenDuration = 30 * 24 * time.Hour
+
+// NewRefreshToken returns a token of the form <session id>.<secret> and the hash to store on the session.
+// A new token is issued on every refresh; presenting an older one is treated as theft.
+func NewRefreshToken(sessionID uuid.UUID) (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = sessionID.String() + "." + hex.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+// ParseRefreshToken returns the session a refresh token claims to belong to.
+func ParseRefreshToken(token string) (sessionID uuid.UUID, ok bool) {
+	id, secret, found := strings.Cut(token, ".")
+	if !found || secret == "" {
+		return uuid.Nil, false
+	}
+	sessionID, err := uuid.Parse(id)
+	return sessionID, err == nil
+}
+
+// RefreshTokenMatches compares token against the session's stored hash in constant time.
+func RefreshTokenMatches(token, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(hash)) == 1
+}
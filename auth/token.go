@@ -0,0 +1,19 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NewMailToken returns a random token to email to a user and the hash to store for it.
+func NewMailToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+// HashMailToken returns the stored form of a token produced by NewMailToken.
+func HashMailToken(token string) string { return hashToken(token) }
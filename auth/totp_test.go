@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B vector for SHA-1, truncated to 6 digits.
+func TestTOTPCodeRFCVector(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	code, err := TOTPCode(secret, time.Unix(59, 0))
+	if err != nil || code != "287082" {
+		t.Fatalf("TOTPCode: got %q, %v, want 287082", code, err)
+	}
+}
+
+func TestValidateTOTPRejectsReplay(t *testing.T) {
+	secret, _ := NewTOTPSecret()
+	now := time.Now()
+	code, _ := TOTPCode(secret, now)
+	step, ok := ValidateTOTP(secret, code, now, 0)
+	if !ok {
+		t.Fatal("valid code rejected")
+	}
+	if _, ok := ValidateTOTP(secret, code, now, step); ok {
+		t.Error("replayed code accepted")
+	}
+}
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes(3)
+	if err != nil || len(codes) != 3 {
+		t.Fatalf("NewRecoveryCodes: %v, %v", codes, err)
+	}
+	remaining, ok := UseRecoveryCode(hashes, " "+codes[1]+" ")
+	if !ok || len(remaining) != 2 {
+		t.Fatalf("UseRecoveryCode: ok=%v remaining=%d", ok, len(remaining))
+	}
+	if _, ok := UseRecoveryCode(remaining, codes[1]); ok {
+		t.Error("recovery code accepted twice")
+	}
+}
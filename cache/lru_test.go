@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[string, int](2, 0)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a should be cached")
+	}
+	c.Set("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should have been evicted as least recently used")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Get(a) = %v, %v; want 1, true", v, ok)
+	}
+	s := c.Stats()
+	if s.Hits != 2 || s.Misses != 1 || s.Evictions != 1 || s.Size != 2 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := New[string, int](10, time.Minute)
+	c.now = func() time.Time { return now }
+	c.Set("a", 1)
+	now = now.Add(59 * time.Second)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a should still be fresh")
+	}
+	now = now.Add(2 * time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a should have expired")
+	}
+	if c.Len() != 0 {
+		t.Errorf("expired entry not removed, len %d", c.Len())
+	}
+}
+
+func TestLRUDeleteFunc(t *testing.T) {
+	c := New[string, int](10, 0)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 1)
+	if n := c.DeleteFunc(func(_ string, v int) bool { return v == 1 }); n != 2 {
+		t.Errorf("DeleteFunc removed %d, want 2", n)
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Error("b should remain")
+	}
+}
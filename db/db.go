@@ -3,22 +3,36 @@ package db
 import (
 	"log"
 	"os"
+	"strings"
 
-	"APPDROP/models"
-
-	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
+// Connect opens the database and, unless DB_AUTO_MIGRATE=false, applies pending migrations.
 func Connect() {
+	Open()
 
-	if err := godotenv.Load(); err != nil {
-		log.Println(" No .env file found, using system environment variables")
+	if strings.EqualFold(os.Getenv("DB_AUTO_MIGRATE"), "false") {
+		return
+	}
+	migrator, err := Migrations()
+	if err != nil {
+		log.Fatal("Failed to load migrations:", err)
 	}
+	applied, err := migrator.Up()
+	if err != nil {
+		log.Fatal("Failed to apply migrations:", err)
+	}
+	if len(applied) > 0 {
+		log.Println("Applied migrations:", applied)
+	}
+}
 
+// Open connects to PostgreSQL without touching the schema.
+func Open() {
 	dsn := os.Getenv("DATABASE_URL")
 	if dsn == "" {
 		log.Fatal(" DATABASE_URL is not set")
@@ -31,20 +45,13 @@ func Connect() {
 
 	DB = database
 	log.Println("Connected to PostgreSQL")
+}
 
-	_ = DB.Exec(`ALTER TABLE pages DROP CONSTRAINT IF EXISTS pages_route_key`).Error
-	_ = DB.Exec(`ALTER TABLE pages DROP CONSTRAINT IF EXISTS uni_pages_route`).Error
-	_ = DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_pages_brand_route ON pages(brand_id, route)`).Error
-
-	if err := DB.AutoMigrate(&models.Page{}, &models.Widget{}); err != nil {
-		log.Println("Failed to migrate Pages/Widgets:", err)
-	}
-
-	if err := DB.Exec(`ALTER TABLE brands ADD COLUMN IF NOT EXISTS email text;`).Error; err != nil {
-		log.Println("Failed to add email column:", err)
-	}
-
-	if err := DB.Exec(`ALTER TABLE brands ADD COLUMN IF NOT EXISTS password_hash text;`).Error; err != nil {
-		log.Println("Failed to add password_hash column:", err)
+// Migrations returns a Migrator bound to the open connection. Call Open or Connect first.
+func Migrations() (*Migrator, error) {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return nil, err
 	}
+	return NewMigrator(sqlDB)
 }
@@ -0,0 +1,312 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the pg_advisory_xact_lock key that serialises concurrent migration runs.
+const migrationLockID = 7345501
+
+var (
+	migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	nonNameChars         = regexp.MustCompile(`[^a-z0-9_]+`)
+)
+
+// Migration is one numbered schema change, loaded from a NNNN_name.up.sql / NNNN_name.down.sql pair.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus describes a known migration and whether it has been applied.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// LoadMigrations reads every migration in dir of fsys, sorted by version.
+// Each version needs an up file; the down file is optional but required to roll it back.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		m := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: name must look like 0001_name.up.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d: conflicting names %q and %q", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(content)
+			sum := sha256.Sum256(content)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s: missing up file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies and rolls back migrations, recording them in schema_migrations.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator for the migrations embedded in this package.
+func NewMigrator(sqlDB *sql.DB) (*Migrator, error) {
+	migrations, err := LoadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: sqlDB, migrations: migrations}, nil
+}
+
+func (m *Migrator) ensureTable() error {
+	_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`)
+	return err
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func readApplied(q queryer) (map[int]appliedMigration, error) {
+	rows, err := q.Query(`SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]appliedMigration{}
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// verify fails if an applied migration was edited after it ran or no longer exists.
+func (m *Migrator) verify(applied map[int]appliedMigration) error {
+	known := map[int]Migration{}
+	for _, mig := range m.migrations {
+		known[mig.Version] = mig
+	}
+	for version, a := range applied {
+		mig, ok := known[version]
+		if !ok {
+			return fmt.Errorf("migration %d_%s is applied but missing from this build", version, a.name)
+		}
+		if mig.Checksum != a.checksum {
+			return fmt.Errorf("migration %d_%s was modified after being applied (checksum mismatch)", version, mig.Name)
+		}
+	}
+	return nil
+}
+
+// Up applies every pending migration in order, each in its own transaction. It returns the versions applied.
+func (m *Migrator) Up() ([]int, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	var done []int
+	for _, mig := range m.migrations {
+		ran, err := m.runLocked(func(tx *sql.Tx, applied map[int]appliedMigration) (bool, error) {
+			if _, ok := applied[mig.Version]; ok {
+				return false, nil
+			}
+			if _, err := tx.Exec(mig.Up); err != nil {
+				return false, fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+				mig.Version, mig.Name, mig.Checksum)
+			return true, err
+		})
+		if err != nil {
+			return done, err
+		}
+		if ran {
+			done = append(done, mig.Version)
+		}
+	}
+	return done, nil
+}
+
+// Down rolls back the most recently applied steps migrations, newest first. It returns the versions rolled back.
+func (m *Migrator) Down(steps int) ([]int, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	var done []int
+	for i := 0; i < steps; i++ {
+		var version int
+		ran, err := m.runLocked(func(tx *sql.Tx, applied map[int]appliedMigration) (bool, error) {
+			var latest *Migration
+			for j := len(m.migrations) - 1; j >= 0; j-- {
+				if _, ok := applied[m.migrations[j].Version]; ok {
+					latest = &m.migrations[j]
+					break
+				}
+			}
+			if latest == nil {
+				return false, nil
+			}
+			if latest.Down == "" {
+				return false, fmt.Errorf("migration %d_%s has no down file", latest.Version, latest.Name)
+			}
+			if _, err := tx.Exec(latest.Down); err != nil {
+				return false, fmt.Errorf("rollback %d_%s: %w", latest.Version, latest.Name, err)
+			}
+			version = latest.Version
+			_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, latest.Version)
+			return true, err
+		})
+		if err != nil {
+			return done, err
+		}
+		if !ran {
+			break
+		}
+		done = append(done, version)
+	}
+	return done, nil
+}
+
+// Status lists every known migration with its applied time, if any.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	applied, err := readApplied(m.db)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.verify(applied); err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if a, ok := applied[mig.Version]; ok {
+			at := a.appliedAt
+			s.AppliedAt = &at
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// runLocked runs fn in a transaction holding the migration lock, after re-reading and verifying applied migrations.
+func (m *Migrator) runLocked(fn func(tx *sql.Tx, applied map[int]appliedMigration) (bool, error)) (bool, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return false, err
+	}
+	applied, err := readApplied(tx)
+	if err != nil {
+		return false, err
+	}
+	if err := m.verify(applied); err != nil {
+		return false, err
+	}
+	ran, err := fn(tx, applied)
+	if err != nil {
+		return false, err
+	}
+	if !ran {
+		return false, nil
+	}
+	return true, tx.Commit()
+}
+
+// CreateMigrationFiles writes an empty up/down pair for the next version into dir and returns their paths.
+func CreateMigrationFiles(dir, name string) (string, string, error) {
+	name = strings.Trim(nonNameChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", errors.New("migration name is required")
+	}
+	existing, err := LoadMigrations(os.DirFS(dir), ".")
+	if err != nil {
+		return "", "", err
+	}
+	next := 1
+	if len(existing) > 0 {
+		next = existing[len(existing)-1].Version + 1
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", next, name))
+	up, down := base+".up.sql", base+".down.sql"
+	if err := os.WriteFile(up, []byte("-- "+name+"\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- Reverts "+name+"\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
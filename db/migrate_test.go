@@ -0,0 +1,194 @@
+package db
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestLoadMigrations_Embedded(t *testing.T) {
+	migrations, err := LoadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatalf("load embedded migrations: %v", err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d: got version %d, want contiguous versions starting at 1", i, m.Version)
+		}
+		if m.Down == "" {
+			t.Errorf("migration %d_%s: missing down file", m.Version, m.Name)
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_second.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"m/0001_first.up.sql":    {Data: []byte("CREATE TABLE a ();")},
+		"m/0001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+		"m/0002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+	}
+	migrations, err := LoadMigrations(fsys, "m")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "first" || migrations[1].Name != "second" {
+		t.Fatalf("got %+v, want first then second", migrations)
+	}
+	if migrations[0].Down != "DROP TABLE a;" {
+		t.Errorf("down: got %q", migrations[0].Down)
+	}
+
+	changed := fstest.MapFS{"m/0001_first.up.sql": {Data: []byte("CREATE TABLE a (id int);")}}
+	again, err := LoadMigrations(changed, "m")
+	if err != nil {
+		t.Fatalf("load changed: %v", err)
+	}
+	if again[0].Checksum == migrations[0].Checksum {
+		t.Error("checksum did not change when the up file changed")
+	}
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"bad name":   {"m/first.sql": {Data: []byte("")}},
+		"missing up": {"m/0001_first.down.sql": {Data: []byte("")}},
+		"name clash": {"m/0001_a.up.sql": {Data: []byte("")}, "m/0001_b.up.sql": {Data: []byte("")}},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadMigrations(fsys, "m"); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestCreateMigrationFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "0003_existing.up.sql"), []byte("SELECT 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	up, down, err := CreateMigrationFiles(dir, "Add Widget Tags")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if filepath.Base(up) != "0004_add_widget_tags.up.sql" || filepath.Base(down) != "0004_add_widget_tags.down.sql" {
+		t.Errorf("got %s and %s", up, down)
+	}
+	if _, _, err := CreateMigrationFiles(dir, "!!!"); err == nil {
+		t.Error("expected an error for an empty name")
+	}
+}
+
+// withSearchPath points dsn, a URL or key=value connection string, at schema.
+func withSearchPath(dsn, schema string) string {
+	if u, err := url.Parse(dsn); err == nil && strings.Contains(dsn, "://") {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return dsn + " search_path=" + schema
+}
+
+// TestMigrations_LegacyBootstrap migrates a schema set up the way db.Connect did before there were
+// migrations, and checks that deleting a brand still removes its pages and their widgets. It needs a
+// PostgreSQL database in TEST_DATABASE_URL and works in a schema of its own.
+func TestMigrations_LegacyBootstrap(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	schema := fmt.Sprintf("legacy_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+	conn, err := gorm.Open(postgres.Open(withSearchPath(dsn, schema)), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("connect to schema: %v", err)
+	}
+
+	// The old bootstrap: brands from schema.sql, then AutoMigrate of these models, which made pages.brand_id
+	// without a foreign key and fk_pages_widgets without ON DELETE CASCADE.
+	type Widget struct {
+		ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+		PageID    uuid.UUID `gorm:"type:uuid;not null"`
+		Type      string
+		Position  int
+		Config    map[string]any `gorm:"type:jsonb"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	type Page struct {
+		ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+		BrandID   uuid.UUID `gorm:"type:uuid;not null"`
+		Name      string
+		Route     string
+		IsHome    bool
+		Widgets   []Widget `gorm:"foreignKey:PageID"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	if err := conn.Exec(`CREATE TABLE brands (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		name TEXT NOT NULL,
+		logo TEXT,
+		office_address TEXT,
+		domain TEXT UNIQUE NOT NULL,
+		created_at TIMESTAMP DEFAULT NOW(),
+		updated_at TIMESTAMP DEFAULT NOW()
+	)`).Error; err != nil {
+		t.Fatalf("create legacy brands: %v", err)
+	}
+	if err := conn.AutoMigrate(&Page{}, &Widget{}); err != nil {
+		t.Fatalf("legacy AutoMigrate: %v", err)
+	}
+
+	sqlDB, err := conn.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := NewMigrator(sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("migrate legacy schema: %v", err)
+	}
+
+	var brandID, pageID uuid.UUID
+	if err := conn.Raw(`INSERT INTO brands (name, domain) VALUES ('Legacy', 'legacy') RETURNING id`).Row().Scan(&brandID); err != nil {
+		t.Fatalf("insert brand: %v", err)
+	}
+	if err := conn.Raw(`INSERT INTO pages (brand_id, name, route) VALUES (?, 'Home', '/') RETURNING id`, brandID).Row().Scan(&pageID); err != nil {
+		t.Fatalf("insert page: %v", err)
+	}
+	if err := conn.Exec(`INSERT INTO widgets (page_id, type, position) VALUES (?, 'text', 0)`, pageID).Error; err != nil {
+		t.Fatalf("insert widget: %v", err)
+	}
+	if err := conn.Exec(`DELETE FROM brands WHERE id = ?`, brandID).Error; err != nil {
+		t.Fatalf("delete brand: %v", err)
+	}
+	var left int64
+	if err := conn.Raw(`SELECT (SELECT COUNT(*) FROM pages) + (SELECT COUNT(*) FROM widgets)`).Row().Scan(&left); err != nil {
+		t.Fatalf("count pages and widgets: %v", err)
+	}
+	if left != 0 {
+		t.Errorf("after deleting the brand: %d pages and widgets left, want 0", left)
+	}
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS widgets;
+DROP TABLE IF EXISTS pages;
+DROP TABLE IF EXISTS brands;
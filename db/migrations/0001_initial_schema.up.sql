@@ -0,0 +1,41 @@
+-- Brands, pages and widgets. Written to be safe on databases created by the old AutoMigrate/ALTER bootstrap.
+CREATE EXTENSION IF NOT EXISTS "pgcrypto";
+
+CREATE TABLE IF NOT EXISTS brands (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    logo TEXT,
+    office_address TEXT,
+    domain TEXT UNIQUE NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+ALTER TABLE brands ADD COLUMN IF NOT EXISTS email TEXT;
+ALTER TABLE brands ADD COLUMN IF NOT EXISTS password_hash TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_brands_email ON brands(email);
+
+CREATE TABLE IF NOT EXISTS pages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    brand_id UUID NOT NULL REFERENCES brands(id) ON DELETE CASCADE,
+    name TEXT,
+    route TEXT,
+    is_home BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS brand_id UUID REFERENCES brands(id) ON DELETE CASCADE;
+-- Routes used to be globally unique; they are only unique per brand.
+ALTER TABLE pages DROP CONSTRAINT IF EXISTS pages_route_key;
+ALTER TABLE pages DROP CONSTRAINT IF EXISTS uni_pages_route;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_pages_brand_route ON pages(brand_id, route);
+
+CREATE TABLE IF NOT EXISTS widgets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    page_id UUID NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    type TEXT,
+    position BIGINT,
+    config JSONB,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_widgets_page_position ON widgets(page_id, position);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS users (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    brand_id UUID NOT NULL REFERENCES brands(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    password_hash TEXT NOT NULL,
+    role TEXT NOT NULL DEFAULT 'viewer',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_brand_email ON users(brand_id, email);
+
+-- Brands created before multi-user support get their login moved into an owner account.
+INSERT INTO users (brand_id, email, password_hash, role, created_at, updated_at)
+SELECT b.id, lower(b.email), b.password_hash, 'owner', NOW(), NOW() FROM brands b
+WHERE b.email IS NOT NULL AND b.password_hash IS NOT NULL
+AND NOT EXISTS (SELECT 1 FROM users u WHERE u.brand_id = b.id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS published_pages;
+ALTER TABLE pages DROP COLUMN IF EXISTS published_at;
+ALTER TABLE pages DROP COLUMN IF EXISTS status;
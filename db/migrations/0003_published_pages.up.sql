@@ -0,0 +1,13 @@
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'draft';
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS published_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS published_pages (
+    page_id UUID PRIMARY KEY REFERENCES pages(id) ON DELETE CASCADE,
+    brand_id UUID NOT NULL REFERENCES brands(id) ON DELETE CASCADE,
+    route TEXT NOT NULL,
+    is_home BOOLEAN DEFAULT FALSE,
+    snapshot JSONB NOT NULL,
+    published_by UUID,
+    published_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_published_pages_brand_route ON published_pages(brand_id, route);
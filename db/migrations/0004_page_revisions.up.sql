@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS page_revisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    page_id UUID NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    brand_id UUID NOT NULL REFERENCES brands(id) ON DELETE CASCADE,
+    number BIGINT NOT NULL,
+    action TEXT NOT NULL,
+    user_id UUID,
+    snapshot JSONB NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_page_revisions_page_number ON page_revisions(page_id, number);
+CREATE INDEX IF NOT EXISTS idx_page_revisions_brand_id ON page_revisions(brand_id);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS widget_types (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    brand_id UUID NOT NULL REFERENCES brands(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    display_name TEXT,
+    description TEXT,
+    icon TEXT,
+    schema JSONB NOT NULL,
+    default_config JSONB,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_widget_types_brand_name ON widget_types(brand_id, name);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS brand_domains;
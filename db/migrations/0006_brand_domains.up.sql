@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS brand_domains (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    brand_id UUID NOT NULL REFERENCES brands(id) ON DELETE CASCADE,
+    hostname TEXT NOT NULL,
+    verification_token TEXT NOT NULL,
+    verified_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_brand_domains_brand_hostname ON brand_domains(brand_id, hostname);
+-- Several brands may claim a hostname, but only one can prove it.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_brand_domains_verified_hostname ON brand_domains(hostname) WHERE verified_at IS NOT NULL;
@@ -0,0 +1,8 @@
+-- Failed login counters shared by all instances when LOGIN_THROTTLE_STORE=postgres.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL DEFAULT 'epoch',
+    locked_until TIMESTAMPTZ NOT NULL DEFAULT 'epoch'
+);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_last_failure_at ON login_attempts(last_failure_at);
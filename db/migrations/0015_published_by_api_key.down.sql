@@ -0,0 +1 @@
+ALTER TABLE published_pages DROP COLUMN IF EXISTS published_by_api_key_id;
@@ -0,0 +1 @@
+ALTER TABLE published_pages ADD COLUMN IF NOT EXISTS published_by_api_key_id UUID;
@@ -0,0 +1,2 @@
+-- Sessions started before this migration have no hash and must sign in again to refresh.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS csrf_token_hash TEXT NOT NULL DEFAULT '';
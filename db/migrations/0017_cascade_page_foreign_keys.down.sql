@@ -0,0 +1 @@
+-- The constraints are the ones 0001 creates on a fresh database, so there is nothing to undo.
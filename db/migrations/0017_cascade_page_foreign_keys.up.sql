@@ -0,0 +1,15 @@
+-- Databases from the old AutoMigrate bootstrap have no pages -> brands foreign key (0001 only adds it with a
+-- new brand_id column), or schema.sql's with ON DELETE SET NULL, and widgets -> pages as fk_pages_widgets
+-- without a cascade. Recreate both under the names a fresh 0001 gives them, so deletes cascade everywhere.
+
+-- Rows orphaned while there was no cascade would fail the new constraints; nothing can reach them.
+DELETE FROM pages WHERE brand_id IS NULL OR NOT EXISTS (SELECT 1 FROM brands WHERE brands.id = pages.brand_id);
+DELETE FROM widgets WHERE NOT EXISTS (SELECT 1 FROM pages WHERE pages.id = widgets.page_id);
+
+ALTER TABLE pages DROP CONSTRAINT IF EXISTS pages_brand_id_fkey;
+ALTER TABLE pages DROP CONSTRAINT IF EXISTS fk_brands_pages;
+ALTER TABLE pages ADD CONSTRAINT pages_brand_id_fkey FOREIGN KEY (brand_id) REFERENCES brands(id) ON DELETE CASCADE;
+
+ALTER TABLE widgets DROP CONSTRAINT IF EXISTS widgets_page_id_fkey;
+ALTER TABLE widgets DROP CONSTRAINT IF EXISTS fk_pages_widgets;
+ALTER TABLE widgets ADD CONSTRAINT widgets_page_id_fkey FOREIGN KEY (page_id) REFERENCES pages(id) ON DELETE CASCADE;
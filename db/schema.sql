@@ -1,34 +0,0 @@
-CREATE EXTENSION IF NOT EXISTS "pgcrypto";
-
-CREATE TABLE pages (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    name TEXT NOT NULL,
-    route TEXT UNIQUE NOT NULL,
-    is_home BOOLEAN DEFAULT FALSE,
-    created_at TIMESTAMP DEFAULT NOW(),
-    updated_at TIMESTAMP DEFAULT NOW()
-);
-
-CREATE TABLE widgets (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    page_id UUID NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
-    type TEXT NOT NULL,
-    position INT NOT NULL,
-    config JSONB,
-    created_at TIMESTAMP DEFAULT NOW(),
-    updated_at TIMESTAMP DEFAULT NOW()
-);
-CREATE TABLE brands (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    name TEXT NOT NULL,
-    logo TEXT,
-    office_address TEXT,
-    domain TEXT UNIQUE NOT NULL,
-    created_at TIMESTAMP DEFAULT NOW(),
-    updated_at TIMESTAMP DEFAULT NOW()
-);
-ALTER TABLE pages ADD COLUMN brand_id UUID REFERENCES brands(id) ON DELETE SET NULL;
-
-
-
-CREATE UNIQUE INDEX idx_pages_brand_route ON pages(brand_id, route);
@@ -0,0 +1,69 @@
+// Package dnsverify proves ownership of a custom hostname through a DNS TXT record.
+package dnsverify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+)
+
+// ChallengePrefix is the label prepended to a hostname to form the TXT record name.
+const ChallengePrefix = "_appdrop-challenge."
+
+// TokenPrefix starts every TXT value so the record is recognisable among others on the same name.
+const TokenPrefix = "appdrop-verify="
+
+// Resolver looks up TXT records. *net.Resolver satisfies it; tests substitute a StaticResolver.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DefaultResolver uses the system DNS configuration.
+var DefaultResolver Resolver = net.DefaultResolver
+
+// ChallengeName returns the record name that must hold the token for hostname.
+func ChallengeName(hostname string) string {
+	return ChallengePrefix + hostname
+}
+
+// NewToken returns a random TXT value to publish for a new hostname.
+func NewToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return TokenPrefix + hex.EncodeToString(b), nil
+}
+
+// Verify reports whether the challenge record for hostname contains token.
+// A missing record is not an error: it simply means the hostname isn't verified yet.
+func Verify(ctx context.Context, r Resolver, hostname, token string) (bool, error) {
+	records, err := r.LookupTXT(ctx, ChallengeName(hostname))
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, record := range records {
+		if strings.TrimSpace(record) == token {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// StaticResolver answers TXT lookups from a fixed map of record name to values.
+type StaticResolver map[string][]string
+
+func (s StaticResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, ok := s[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
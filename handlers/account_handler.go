@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"APPDROP/auth"
+	"APPDROP/mailer"
+	"APPDROP/models"
+	"APPDROP/store"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 48 * time.Hour
+)
+
+var errTokenInvalid = errors.New("token invalid, expired or already used")
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// mailToken issues a fresh token for purpose, invalidating the user's earlier ones, and mails it.
+func (h *Handler) mailToken(ctx context.Context, brand *models.Brand, user *models.User, purpose string) error {
+	if h.Mailer == nil {
+		return errors.New("no mailer configured")
+	}
+	token, hash, err := auth.NewMailToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	ttl, subject, path, intro := passwordResetTTL, "Reset your password", "/reset-password",
+		"Someone asked to reset the password for your "+brand.Name+" account. If it wasn't you, ignore this email."
+	if purpose == models.TokenEmailVerification {
+		ttl, subject, path, intro = emailVerificationTTL, "Verify your email address", "/verify-email",
+			"Confirm that this address belongs to your "+brand.Name+" account."
+	}
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.UserTokens().InvalidateUser(ctx, user.ID, purpose, now); err != nil {
+			return err
+		}
+		return tx.UserTokens().Create(ctx, &models.UserToken{
+			BrandID:   brand.ID,
+			UserID:    user.ID,
+			Purpose:   purpose,
+			TokenHash: hash,
+			ExpiresAt: now.Add(ttl),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "%s\n\n", intro)
+	if h.AppURL != "" {
+		link := strings.TrimRight(h.AppURL, "/") + path + "?" + url.Values{"token": {token}, "brand": {brand.Domain}}.Encode()
+		fmt.Fprintf(&body, "Open this link within %s:\n%s\n", ttl, link)
+	} else {
+		fmt.Fprintf(&body, "Use this token within %s:\n%s\n", ttl, token)
+	}
+	return h.Mailer.Send(ctx, mailer.Message{To: user.Email, Subject: subject, Body: body.String()})
+}
+
+// redeemToken consumes a mailed token for purpose and applies fn to its user in the same transaction.
+func (h *Handler) redeemToken(ctx context.Context, brandID uuid.UUID, token, purpose string, fn func(tx store.Store, user *models.User) error) error {
+	if token == "" {
+		return errTokenInvalid
+	}
+	now := time.Now()
+	return h.Store.Tx(ctx, func(tx store.Store) error {
+		t, err := tx.UserTokens().GetByHash(ctx, purpose, auth.HashMailToken(token))
+		if err != nil || !t.Usable(now) || t.BrandID != brandID {
+			return errTokenInvalid
+		}
+		if err := tx.UserTokens().MarkUsed(ctx, t.ID, now); err != nil {
+			return errTokenInvalid
+		}
+		user, err := tx.Users().Get(ctx, t.BrandID, t.UserID)
+		if err != nil {
+			return errTokenInvalid
+		}
+		if user.EmailVerifiedAt == nil {
+			// Redeeming any mailed token proves the user reads mail at this address.
+			user.EmailVerifiedAt = &now
+		}
+		if err := fn(tx, user); err != nil {
+			return err
+		}
+		return tx.Users().Update(ctx, user)
+	})
+}
+
+// ForgotPassword mails a reset token if the email belongs to a user of the brand. The response is the same
+// either way so it can't be used to discover accounts.
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	brand, ok := getBrandFromContext(c)
+	if !ok || brand == nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Email) == "" {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "email is required")
+		return
+	}
+	ctx := c.Request.Context()
+	if user, err := h.Store.Users().GetByEmail(ctx, brand.ID, normalizeEmail(req.Email)); err == nil {
+		if err := h.mailToken(ctx, brand, user, models.TokenPasswordReset); err != nil {
+			c.Error(fmt.Errorf("password reset mail: %w", err))
+		}
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "If an account exists for that email, a reset link has been sent"})
+}
+
+// ResetPassword sets a new password from a reset token and signs the user out of every session.
+func (h *Handler) ResetPassword(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if req.NewPassword == "" {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "new_password is required")
+		return
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to hash password")
+		return
+	}
+	ctx := c.Request.Context()
+	err = h.redeemToken(ctx, brandID, req.Token, models.TokenPasswordReset, func(tx store.Store, user *models.User) error {
+		user.PasswordHash = string(hashedPassword)
+		return revokeUserSessions(ctx, tx, user, uuid.Nil)
+	})
+	if errors.Is(err, errTokenInvalid) {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Reset token is invalid, expired or already used")
+		return
+	}
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to reset password")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "password updated"})
+}
+
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	err := h.redeemToken(c.Request.Context(), brandID, req.Token, models.TokenEmailVerification,
+		func(store.Store, *models.User) error { return nil })
+	if errors.Is(err, errTokenInvalid) {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Verification token is invalid, expired or already used")
+		return
+	}
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to verify email")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "email verified"})
+}
+
+// ResendEmailVerification mails the signed-in user a new verification token.
+func (h *Handler) ResendEmailVerification(c *gin.Context) {
+	brand, ok := getBrandFromContext(c)
+	if !ok || brand == nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	user, ok := sessionUser(c)
+	if !ok {
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "email already verified"})
+		return
+	}
+	if err := h.mailToken(c.Request.Context(), brand, user, models.TokenEmailVerification); err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to send verification email")
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "verification email sent"})
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"APPDROP/auth"
+	"APPDROP/middlewares"
+	"APPDROP/models"
+	"APPDROP/store"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyCreatedResponse carries the full key, which is never retrievable again after creation.
+type APIKeyCreatedResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	user, ok := sessionUser(c)
+	if !ok {
+		return
+	}
+	keys, err := h.Store.APIKeys().List(c.Request.Context(), user.BrandID)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch API keys")
+		return
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// CreateAPIKey issues a key limited to scopes the creating user holds themselves.
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	user, ok := sessionUser(c)
+	if !ok {
+		return
+	}
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "name is required")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "at least one scope is required")
+		return
+	}
+	var scopes []string
+	for _, scope := range req.Scopes {
+		if scope == middlewares.PermAPIKeysWrite || !middlewares.HasPermission(user.Role, scope) {
+			RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "scope not allowed: "+scope)
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "expires_at must be in the future")
+		return
+	}
+
+	key, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create API key")
+		return
+	}
+	createdBy := user.ID
+	apiKey := models.APIKey{
+		BrandID:   user.BrandID,
+		CreatedBy: &createdBy,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	ctx := c.Request.Context()
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.APIKeys().Create(ctx, &apiKey); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditCreate, EntityType: models.AuditEntityAPIKey, EntityID: apiKey.ID}, nil, apiKey)
+	})
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create API key")
+		return
+	}
+	c.JSON(http.StatusCreated, APIKeyCreatedResponse{APIKey: apiKey, Key: key})
+}
+
+// RevokeAPIKey stops a key from authenticating. The record is kept so it still shows up in listings.
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	user, ok := sessionUser(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid API key ID")
+		return
+	}
+	ctx := c.Request.Context()
+	apiKey, err := h.Store.APIKeys().Get(ctx, user.BrandID, id)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "API key not found")
+		return
+	}
+	if apiKey.RevokedAt == nil {
+		before := *apiKey
+		now := time.Now()
+		apiKey.RevokedAt = &now
+		err := h.Store.Tx(ctx, func(tx store.Store) error {
+			if err := tx.APIKeys().Update(ctx, apiKey); err != nil {
+				return err
+			}
+			return recordAudit(c, tx, models.AuditEvent{Action: models.AuditRevoke, EntityType: models.AuditEntityAPIKey, EntityID: apiKey.ID}, before, apiKey)
+		})
+		if err != nil {
+			RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to revoke API key")
+			return
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
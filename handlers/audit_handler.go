@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"APPDROP/middlewares"
+	"APPDROP/models"
+	"APPDROP/store"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// auditIgnoredFields change on every write and would only add noise to diffs.
+var auditIgnoredFields = map[string]bool{"created_at": true, "updated_at": true}
+
+// passwordChange shows a password being set in a user's audit diff, which the user's JSON alone can't.
+type passwordChange struct {
+	*models.User
+	PasswordChanged bool `json:"password_changed"`
+}
+
+// recordAudit appends an audit event for the current request, filling in the actor, IP, request ID and,
+// when event.BrandID is unset, the current brand. before and after are the entity's states (nil when it
+// did not or no longer exists); only the JSON fields that differ are kept, so secrets hidden from JSON
+// never reach the log. Call it inside the transaction making the change, so the change and its record
+// commit together.
+func recordAudit(c *gin.Context, s store.Store, event models.AuditEvent, before, after any) error {
+	if event.BrandID == uuid.Nil {
+		event.BrandID, _ = getBrandID(c)
+	}
+	event.ActorUserID = currentUserID(c)
+	event.ActorAPIKeyID = currentAPIKeyID(c)
+	event.IPAddress = c.ClientIP()
+	event.RequestID = c.GetString(middlewares.ContextKeyRequestID)
+	changes, err := auditDiff(before, after)
+	if err != nil {
+		return err
+	}
+	event.Changes = changes
+	return s.Audit().Create(c.Request.Context(), &event)
+}
+
+// auditDiff compares the top-level JSON fields of before and after.
+func auditDiff(before, after any) (map[string]models.AuditChange, error) {
+	b, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]models.AuditChange{}
+	for k, v := range b {
+		if w, ok := a[k]; !ok || !reflect.DeepEqual(v, w) {
+			changes[k] = models.AuditChange{Before: v, After: a[k]}
+		}
+	}
+	for k, w := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = models.AuditChange{After: w}
+		}
+	}
+	return changes, nil
+}
+
+func auditFields(v any) (map[string]any, error) {
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil() {
+		return nil, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	for k := range auditIgnoredFields {
+		delete(fields, k)
+	}
+	return fields, nil
+}
+
+// ListAuditEvents returns the brand's audit log, newest first. Filters: entity_type, entity_id, action,
+// actor_id (a user or API key ID), and since/until as RFC 3339 times.
+func (h *Handler) ListAuditEvents(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	filter := store.AuditFilter{EntityType: c.Query("entity_type"), Action: c.Query("action")}
+	for param, dst := range map[string]**uuid.UUID{"entity_id": &filter.EntityID, "actor_id": &filter.ActorID} {
+		if v := c.Query(param); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid "+param)
+				return
+			}
+			*dst = &id
+		}
+	}
+	for param, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := c.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", param+" must be an RFC 3339 time")
+				return
+			}
+			*dst = t
+		}
+	}
+
+	page := 1
+	limit := 50
+	if parsed, err := strconv.Atoi(c.Query("page")); err == nil && parsed > 0 {
+		page = parsed
+	}
+	if parsed, err := strconv.Atoi(c.Query("limit")); err == nil && parsed > 0 {
+		limit = min(parsed, 200)
+	}
+	filter.Offset, filter.Limit = (page-1)*limit, limit
+
+	ctx := c.Request.Context()
+	total, err := h.Store.Audit().Count(ctx, brandID, filter)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to count audit events")
+		return
+	}
+	events, err := h.Store.Audit().List(ctx, brandID, filter)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch audit events")
+		return
+	}
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":  events,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
@@ -2,11 +2,14 @@ package handlers
 
 import (
 	"APPDROP/auth"
-	"APPDROP/db"
 	"APPDROP/models"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -15,14 +18,13 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
-func Login(c *gin.Context) {
+func (h *Handler) Login(c *gin.Context) {
 	brand, ok := getBrandFromContext(c)
 	if !ok || brand == nil {
 
 		if brandID, ok := getBrandID(c); ok {
-			var b models.Brand
-			if err := db.DB.First(&b, "id = ?", brandID).Error; err == nil {
-				brand = &b
+			if b, err := h.Store.Brands().Get(c.Request.Context(), brandID); err == nil {
+				brand = b
 				ok = true
 			}
 		}
@@ -38,28 +40,113 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	if req.Email != brand.Email {
-		RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid credentials")
+	user, account, ok := h.passwordLogin(c, brand.ID, req.Email, req.Password)
+	if !ok {
+		return
+	}
+	tokens, err := h.startSession(c, user)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create session")
 		return
 	}
+	setSessionCookies(c, tokens)
+	h.loginSucceeded(c, account)
+	c.JSON(http.StatusOK, loginResponse(user, tokens.csrf))
+}
 
+// passwordLogin checks email and password under login throttling and returns the user to start a session for.
+// Otherwise it has already responded: with an error, or with a challenge when the user has two-factor
+// authentication on.
+func (h *Handler) passwordLogin(c *gin.Context, brandID uuid.UUID, email, password string) (*models.User, string, bool) {
+	email = normalizeEmail(email)
+	account := loginAccount(brandID, email)
+	if !h.checkLoginThrottle(c, account) {
+		return nil, "", false
+	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(brand.PasswordHash), []byte(req.Password)); err != nil {
+	user, err := h.Store.Users().GetByEmail(c.Request.Context(), brandID, email)
+	if err == nil {
+		err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	}
+	if err != nil {
+		h.loginFailed(c, account)
 		RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid credentials")
-		return
+		return nil, "", false
 	}
 
-	token, err := auth.CreateToken(brand.ID, req.Email, auth.DefaultTokenDuration)
+	if user.TwoFactorEnabled() {
+		challenge, err := auth.CreateLoginChallenge(brandID, user.ID.String())
+		if err != nil {
+			RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create session")
+			return nil, "", false
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge":           challenge,
+			"expires_in":          int(auth.LoginChallengeDuration.Seconds()),
+		})
+		return nil, "", false
+	}
+	return user, account, true
+}
+
+// loginAccount keys login throttling by brand and email, whether or not such a user exists.
+func loginAccount(brandID uuid.UUID, email string) string {
+	return brandID.String() + ":" + email
+}
+
+// checkLoginThrottle responds 429 TOO_MANY_ATTEMPTS with Retry-After if the client IP or the account
+// is locked out.
+func (h *Handler) checkLoginThrottle(c *gin.Context, account string) bool {
+	wait, err := h.LoginLimiter.Check(c.Request.Context(), c.ClientIP(), account)
 	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create session")
-		return
+		c.Error(fmt.Errorf("login throttle: %w", err))
+		return true
+	}
+	if wait <= 0 {
+		return true
 	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	RespondError(c, http.StatusTooManyRequests, "TOO_MANY_ATTEMPTS", "Too many failed login attempts; try again later")
+	return false
+}
 
-	auth.SetSessionCookie(c.Writer, token, c.Request.Host, false)
-	c.JSON(http.StatusOK, gin.H{"message": "ok", "brand_id": brand.ID.String()})
+func (h *Handler) loginFailed(c *gin.Context, account string) {
+	if err := h.LoginLimiter.Failure(c.Request.Context(), c.ClientIP(), account); err != nil {
+		c.Error(fmt.Errorf("login throttle: %w", err))
+	}
 }
 
-func Logout(c *gin.Context) {
+func (h *Handler) loginSucceeded(c *gin.Context, account string) {
+	if err := h.LoginLimiter.Success(c.Request.Context(), account); err != nil {
+		c.Error(fmt.Errorf("login throttle: %w", err))
+	}
+}
+
+func loginResponse(user *models.User, csrfToken string) gin.H {
+	return gin.H{"message": "ok", "brand_id": user.BrandID.String(), "user_id": user.ID.String(), "role": user.Role, "csrf_token": csrfToken}
+}
+
+// Logout revokes the session identified by the refresh cookie, so neither of its tokens works afterwards.
+// Like /refresh it needs the session's CSRF token, so a cross-site form cannot sign the user out.
+func (h *Handler) Logout(c *gin.Context) {
+	token, _ := c.Cookie(auth.RefreshCookieName())
+	if sessionID, ok := auth.ParseRefreshToken(token); ok {
+		if brandID, ok := getBrandID(c); ok {
+			ctx := c.Request.Context()
+			session, err := h.Store.Sessions().Get(ctx, brandID, sessionID)
+			if err == nil && auth.RefreshTokenMatches(token, session.RefreshTokenHash) {
+				if !auth.CSRFTokenMatches(c.GetHeader(auth.CSRFHeader), session.CSRFTokenHash) {
+					RespondError(c, http.StatusForbidden, "FORBIDDEN", "Missing or invalid CSRF token")
+					return
+				}
+				if err := revokeSession(ctx, h.Store, session); err != nil {
+					RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to end session")
+					return
+				}
+			}
+		}
+	}
 	auth.ClearSessionCookie(c.Writer, c.Request.Host, false)
 	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
 }
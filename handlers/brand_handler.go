@@ -1,16 +1,24 @@
 package handlers
 
 import (
-	"APPDROP/db"
+	"APPDROP/auth"
 	"APPDROP/middlewares"
 	"APPDROP/models"
+	"APPDROP/store"
+	"errors"
+	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// brandDomainPattern matches what BrandResolver can resolve: a single lowercase DNS label.
+var brandDomainPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
 type CreateBrandRequest struct {
 	Name          string `json:"name"`
 	Domain        string `json:"domain"`
@@ -20,7 +28,7 @@ type CreateBrandRequest struct {
 	Password      string `json:"password"`
 }
 
-func CreateBrand(c *gin.Context) {
+func (h *Handler) CreateBrand(c *gin.Context) {
 	var req CreateBrandRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
@@ -32,10 +40,15 @@ func CreateBrand(c *gin.Context) {
 		return
 	}
 
+	req.Domain = normalizeDomain(req.Domain)
 	if req.Domain == "" {
 		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "brand domain is required")
 		return
 	}
+	if msg := validateBrandDomain(req.Domain); msg != "" {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
 
 	if req.Email == "" {
 		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "brand email is required")
@@ -47,14 +60,13 @@ func CreateBrand(c *gin.Context) {
 		return
 	}
 
-	var existingBrand models.Brand
-
-	if err := db.DB.Where("domain = ?", req.Domain).First(&existingBrand).Error; err == nil {
+	ctx := c.Request.Context()
+	if _, err := h.Store.Brands().GetByDomain(ctx, req.Domain); err == nil {
 		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "brand domain already exists")
 		return
 	}
 
-	if err := db.DB.Where("email = ?", req.Email).First(&existingBrand).Error; err == nil {
+	if _, err := h.Store.Brands().GetByEmail(ctx, req.Email); err == nil {
 		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "brand email already exists")
 		return
 	}
@@ -74,13 +86,33 @@ func CreateBrand(c *gin.Context) {
 		PasswordHash:  string(hashedPassword),
 	}
 
-	if err := db.DB.Create(&brand).Error; err != nil {
+	var owner models.User
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Brands().Create(ctx, &brand); err != nil {
+			return err
+		}
+		owner = models.User{
+			BrandID:      brand.ID,
+			Email:        normalizeEmail(req.Email),
+			PasswordHash: brand.PasswordHash,
+			Role:         models.RoleOwner,
+		}
+		if err := tx.Users().Create(ctx, &owner); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{BrandID: brand.ID, Action: models.AuditCreate, EntityType: models.AuditEntityBrand, EntityID: brand.ID}, nil, brand)
+	})
+	if err != nil {
 		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to create a brand")
 		return
 	}
+	// The brand exists either way; a failed send can be retried with POST /email/verify/resend.
+	if err := h.mailToken(ctx, &brand, &owner, models.TokenEmailVerification); err != nil {
+		c.Error(fmt.Errorf("email verification mail: %w", err))
+	}
 	c.JSON(http.StatusCreated, brand)
 }
-func GetBrandByID(c *gin.Context) {
+func (h *Handler) GetBrandByID(c *gin.Context) {
 	brandVal, exists := c.Get(middlewares.ContextKeyBrand)
 	if !exists {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
@@ -103,7 +135,7 @@ func GetBrandByID(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, ctxBrand)
 }
-func GetBrandMe(c *gin.Context) {
+func (h *Handler) GetBrandMe(c *gin.Context) {
 	brandVal, exists := c.Get(middlewares.ContextKeyBrand)
 	if !exists {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
@@ -116,3 +148,119 @@ func GetBrandMe(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, brand)
 }
+
+type UpdateBrandRequest struct {
+	Name          *string `json:"name"`
+	Logo          *string `json:"logo"`
+	OfficeAddress *string `json:"office_address"`
+	Domain        *string `json:"domain"`
+}
+
+type DeleteBrandRequest struct {
+	Password string `json:"password"`
+}
+
+func normalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimSpace(domain))
+}
+
+func validateBrandDomain(domain string) string {
+	if domain == "www" || !brandDomainPattern.MatchString(domain) {
+		return "brand domain must be 1-63 lowercase letters, digits or hyphens, and not www"
+	}
+	return ""
+}
+
+func (h *Handler) UpdateBrandMe(c *gin.Context) {
+	current, ok := getBrandFromContext(c)
+	if !ok || current == nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	var req UpdateBrandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	ctx := c.Request.Context()
+	brand := *current
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "brand name is required")
+			return
+		}
+		brand.Name = *req.Name
+	}
+	if req.Logo != nil {
+		brand.Logo = *req.Logo
+	}
+	if req.OfficeAddress != nil {
+		brand.OfficeAddress = *req.OfficeAddress
+	}
+	if req.Domain != nil {
+		domain := normalizeDomain(*req.Domain)
+		if msg := validateBrandDomain(domain); msg != "" {
+			RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+			return
+		}
+		if existing, err := h.Store.Brands().GetByDomain(ctx, domain); err == nil && existing.ID != brand.ID {
+			RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "brand domain already exists")
+			return
+		}
+		brand.Domain = domain
+	}
+
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Brands().Update(ctx, &brand); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditUpdate, EntityType: models.AuditEntityBrand, EntityID: brand.ID}, current, brand)
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "brand domain already exists")
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update brand")
+		return
+	}
+	h.BrandCache.InvalidateBrand(brand.ID)
+	c.JSON(http.StatusOK, brand)
+}
+
+// DeleteBrandMe closes the brand's account, removing its users, pages, widgets and published content.
+// The caller must confirm with their own password.
+func (h *Handler) DeleteBrandMe(c *gin.Context) {
+	brand, ok := getBrandFromContext(c)
+	if !ok || brand == nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	user, ok := getCurrentUser(c)
+	if !ok {
+		RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "Missing or invalid session")
+		return
+	}
+	var req DeleteBrandRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Password == "" {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "password is required to delete the brand")
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		RespondError(c, http.StatusForbidden, "FORBIDDEN", "Password is incorrect")
+		return
+	}
+	err := h.Store.Tx(c.Request.Context(), func(tx store.Store) error {
+		if err := tx.Brands().Delete(c.Request.Context(), brand.ID); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditDelete, EntityType: models.AuditEntityBrand, EntityID: brand.ID}, brand, nil)
+	})
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete brand")
+		return
+	}
+	h.BrandCache.InvalidateBrand(brand.ID)
+	auth.ClearSessionCookie(c.Writer, c.Request.Host, false)
+	c.Status(http.StatusNoContent)
+}
@@ -1,5 +1,14 @@
 package handlers
 
+import (
+	"APPDROP/models"
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
 var AllowedWidgetTypes = map[string]bool{
 	"banner":       true,
 	"product_grid": true,
@@ -8,6 +17,74 @@ var AllowedWidgetTypes = map[string]bool{
 	"spacer":       true,
 }
 
-func IsAllowedWidgetType(t string) bool {
-	return AllowedWidgetTypes[t]
+// IsAllowedWidgetType reports whether t is a built-in widget type or one the brand has registered.
+func (h *Handler) IsAllowedWidgetType(ctx context.Context, brandID uuid.UUID, t string) bool {
+	_, ok := h.LookupWidgetType(ctx, brandID, t)
+	return ok
+}
+
+func bound(v float64) *float64 { return &v }
+
+// BuiltinWidgetSchemas defines the config every built-in widget type must satisfy.
+var BuiltinWidgetSchemas = map[string]models.ConfigSchema{
+	"banner": {Fields: map[string]models.FieldSchema{
+		"image_url": {Type: models.FieldString, Required: true, Format: models.FormatURL},
+		"title":     {Type: models.FieldString, Max: bound(120)},
+		"subtitle":  {Type: models.FieldString, Max: bound(240)},
+		"link":      {Type: models.FieldString},
+	}},
+	"product_grid": {Fields: map[string]models.FieldSchema{
+		"columns":     {Type: models.FieldInteger, Required: true, Min: bound(1), Max: bound(6)},
+		"title":       {Type: models.FieldString, Max: bound(120)},
+		"collection":  {Type: models.FieldString},
+		"product_ids": {Type: models.FieldArray, Items: models.FieldString, Max: bound(100)},
+	}},
+	"text": {Fields: map[string]models.FieldSchema{
+		"content": {Type: models.FieldString, Required: true, Min: bound(1)},
+		"align":   {Type: models.FieldString, Enum: []string{"left", "center", "right"}},
+	}},
+	"image": {Fields: map[string]models.FieldSchema{
+		"url":  {Type: models.FieldString, Required: true, Format: models.FormatURL},
+		"alt":  {Type: models.FieldString},
+		"link": {Type: models.FieldString},
+	}},
+	"spacer": {Fields: map[string]models.FieldSchema{
+		"height": {Type: models.FieldInteger, Required: true, Min: bound(0), Max: bound(500)},
+	}},
+}
+
+// LookupWidgetType resolves t to its definition, checking built-ins before the brand's registered types.
+func (h *Handler) LookupWidgetType(ctx context.Context, brandID uuid.UUID, t string) (*models.WidgetType, bool) {
+	if AllowedWidgetTypes[t] {
+		return builtinWidgetType(t), true
+	}
+	widgetType, err := h.Store.WidgetTypes().GetByName(ctx, brandID, t)
+	if err != nil {
+		return nil, false
+	}
+	return widgetType, true
+}
+
+func builtinWidgetType(name string) *models.WidgetType {
+	display := strings.ReplaceAll(name, "_", " ")
+	return &models.WidgetType{
+		Name:        name,
+		DisplayName: strings.ToUpper(display[:1]) + display[1:],
+		Schema:      BuiltinWidgetSchemas[name],
+		Builtin:     true,
+	}
+}
+
+// builtinWidgetTypes returns every built-in type sorted by name.
+func builtinWidgetTypes() []models.WidgetType {
+	names := make([]string, 0, len(AllowedWidgetTypes))
+	for name := range AllowedWidgetTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	types := make([]models.WidgetType, 0, len(names))
+	for _, name := range names {
+		types = append(types, *builtinWidgetType(name))
+	}
+	return types
 }
@@ -3,6 +3,7 @@ package handlers
 import (
 	"APPDROP/middlewares"
 	"APPDROP/models"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -27,3 +28,50 @@ func getBrandFromContext(c *gin.Context) (*models.Brand, bool) {
 	brand, ok := v.(*models.Brand)
 	return brand, ok
 }
+
+// getCurrentUser returns the authenticated user from context (set by RequireAuth). ok is false if missing.
+func getCurrentUser(c *gin.Context) (*models.User, bool) {
+	v, exists := c.Get(middlewares.ContextKeyUser)
+	if !exists {
+		return nil, false
+	}
+	user, ok := v.(*models.User)
+	return user, ok && user != nil
+}
+
+// sessionUser returns the signed-in user, rejecting API key callers with 403. Used by endpoints that
+// manage credentials, which keys must not be able to reach.
+func sessionUser(c *gin.Context) (*models.User, bool) {
+	user, ok := getCurrentUser(c)
+	if !ok {
+		RespondError(c, http.StatusForbidden, "FORBIDDEN", "This endpoint requires a user session")
+		return nil, false
+	}
+	return user, true
+}
+
+// currentSessionID returns the session behind a cookie-authenticated request.
+func currentSessionID(c *gin.Context) (uuid.UUID, bool) {
+	id, ok := c.Value(middlewares.ContextKeySessionID).(uuid.UUID)
+	return id, ok
+}
+
+// currentAPIKeyID returns the ID of the API key authenticating the request, or nil for a user session.
+func currentAPIKeyID(c *gin.Context) *uuid.UUID {
+	key, ok := c.Value(middlewares.ContextKeyAPIKey).(*models.APIKey)
+	if !ok || key == nil {
+		return nil
+	}
+	id := key.ID
+	return &id
+}
+
+// currentUserID returns the authenticated user's ID, or nil when no user is in context.
+func currentUserID(c *gin.Context) *uuid.UUID {
+	user, ok := getCurrentUser(c)
+	if !ok {
+		return nil
+	}
+	id := user.ID
+	return &id
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"APPDROP/dnsverify"
+	"APPDROP/models"
+	"APPDROP/store"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var hostnameLabelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type AddDomainRequest struct {
+	Hostname string `json:"hostname"`
+}
+
+// DomainVerification tells the brand which TXT record to publish.
+type DomainVerification struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type DomainResponse struct {
+	models.BrandDomain
+	Verification DomainVerification `json:"verification"`
+}
+
+func newDomainResponse(d models.BrandDomain) DomainResponse {
+	return DomainResponse{
+		BrandDomain: d,
+		Verification: DomainVerification{
+			Type:  "TXT",
+			Name:  dnsverify.ChallengeName(d.Hostname),
+			Value: d.VerificationToken,
+		},
+	}
+}
+
+// normalizeHostname lowercases hostname and returns "" unless it is a valid multi-label DNS name.
+func normalizeHostname(hostname string) string {
+	hostname = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(hostname)), ".")
+	if len(hostname) > 253 {
+		return ""
+	}
+	labels := strings.Split(hostname, ".")
+	if len(labels) < 2 {
+		return ""
+	}
+	for _, label := range labels {
+		if !hostnameLabelPattern.MatchString(label) {
+			return ""
+		}
+	}
+	return hostname
+}
+
+func (h *Handler) findBrandDomain(c *gin.Context) (*models.BrandDomain, bool) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return nil, false
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid domain ID")
+		return nil, false
+	}
+	domain, err := h.Store.Domains().Get(c.Request.Context(), brandID, id)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Domain not found")
+		return nil, false
+	}
+	return domain, true
+}
+
+func (h *Handler) ListDomains(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	domains, err := h.Store.Domains().List(c.Request.Context(), brandID)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch domains")
+		return
+	}
+	resp := make([]DomainResponse, 0, len(domains))
+	for _, d := range domains {
+		resp = append(resp, newDomainResponse(d))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) AddDomain(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	var req AddDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	hostname := normalizeHostname(req.Hostname)
+	if hostname == "" {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "hostname must be a full DNS name such as shop.example.com")
+		return
+	}
+	ctx := c.Request.Context()
+	if existing, err := h.Store.Domains().GetVerified(ctx, hostname); err == nil && existing.BrandID != brandID {
+		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "hostname is already verified by another brand")
+		return
+	}
+	token, err := dnsverify.NewToken()
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to add domain")
+		return
+	}
+	domain := models.BrandDomain{
+		BrandID:           brandID,
+		Hostname:          hostname,
+		VerificationToken: token,
+	}
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Domains().Create(ctx, &domain); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditCreate, EntityType: models.AuditEntityDomain, EntityID: domain.ID}, nil, newDomainResponse(domain))
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "hostname already added")
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to add domain")
+		return
+	}
+	c.JSON(http.StatusCreated, newDomainResponse(domain))
+}
+
+// VerifyDomain checks the hostname's challenge TXT record and, if it holds the token, starts routing the hostname to the brand.
+func (h *Handler) VerifyDomain(c *gin.Context) {
+	domain, ok := h.findBrandDomain(c)
+	if !ok {
+		return
+	}
+	if domain.Verified() {
+		c.JSON(http.StatusOK, newDomainResponse(*domain))
+		return
+	}
+	ctx := c.Request.Context()
+	verified, err := dnsverify.Verify(ctx, h.Resolver, domain.Hostname, domain.VerificationToken)
+	if err != nil {
+		RespondError(c, http.StatusBadGateway, "INTERNAL_ERROR", "DNS lookup failed, try again later")
+		return
+	}
+	if !verified {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR",
+			"TXT record "+dnsverify.ChallengeName(domain.Hostname)+" does not contain the verification token yet")
+		return
+	}
+	before := newDomainResponse(*domain)
+	now := time.Now()
+	domain.VerifiedAt = &now
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Domains().Update(ctx, domain); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditVerify, EntityType: models.AuditEntityDomain, EntityID: domain.ID}, before, newDomainResponse(*domain))
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "hostname is already verified by another brand")
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to verify domain")
+		return
+	}
+	h.BrandCache.InvalidateHost(domain.Hostname)
+	c.JSON(http.StatusOK, newDomainResponse(*domain))
+}
+
+func (h *Handler) DeleteDomain(c *gin.Context) {
+	domain, ok := h.findBrandDomain(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Domains().Delete(ctx, domain.BrandID, domain.ID); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditDelete, EntityType: models.AuditEntityDomain, EntityID: domain.ID}, newDomainResponse(*domain), nil)
+	})
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete domain")
+		return
+	}
+	h.BrandCache.InvalidateHost(domain.Hostname)
+	c.Status(http.StatusNoContent)
+}
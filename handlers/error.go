@@ -1,13 +1,24 @@
 package handlers
 
-import "github.com/gin-gonic/gin"
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
 
 type ErrorResponse struct {
 	Error ErrorDetail `json:"error"`
 }
 
 type ErrorDetail struct {
-	Code    string `json:"code"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// FieldError points at a single invalid field in the request.
+type FieldError struct {
+	Field   string `json:"field"`
 	Message string `json:"message"`
 }
 
@@ -19,3 +30,22 @@ func RespondError(c *gin.Context, status int, code, message string) {
 		},
 	})
 }
+
+// RespondValidationErrors responds 400 VALIDATION_ERROR with field-level details.
+func RespondValidationErrors(c *gin.Context, message string, details []FieldError) {
+	c.JSON(http.StatusBadRequest, ErrorResponse{
+		Error: ErrorDetail{
+			Code:    "VALIDATION_ERROR",
+			Message: message,
+			Details: details,
+		},
+	})
+}
+
+// validationError carries field-level details out of a transaction so the handler can respond with them.
+type validationError struct {
+	message string
+	details []FieldError
+}
+
+func (e *validationError) Error() string { return e.message }
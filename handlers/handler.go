@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"APPDROP/dnsverify"
+	"APPDROP/mailer"
+	"APPDROP/middlewares"
+	"APPDROP/oidc"
+	"APPDROP/store"
+	"APPDROP/throttle"
+)
+
+// Handler holds the dependencies shared by every HTTP handler.
+type Handler struct {
+	Store store.Store
+	// Resolver answers the TXT lookups that verify custom domains.
+	Resolver dnsverify.Resolver
+	// BrandCache is shared with BrandResolver and invalidated when brands or custom domains change. Nil disables it.
+	BrandCache *middlewares.BrandCache
+	// Mailer delivers password reset and email verification messages.
+	Mailer mailer.Mailer
+	// AppURL is the admin app's base URL, used to build links in emails. Without it emails carry only the token.
+	AppURL string
+	// LoginLimiter locks out IPs and accounts after repeated failed logins. Nil disables throttling.
+	LoginLimiter *throttle.Limiter
+	// OIDC talks to the identity providers brands configure for single sign-on.
+	OIDC *oidc.Client
+}
+
+func New(s store.Store) *Handler {
+	return &Handler{
+		Store:        s,
+		Resolver:     dnsverify.DefaultResolver,
+		LoginLimiter: throttle.NewLimiter(throttle.NewMemory()),
+		OIDC:         oidc.NewClient(nil),
+	}
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"APPDROP/auth"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS publishes the public keys that verify AppDrop access tokens, so other services can check them
+// without sharing a secret. Verifiers should refetch when they see an unknown kid.
+func (h *Handler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, auth.Keys().JWKS())
+}
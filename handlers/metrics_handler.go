@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics serves process counters in the Prometheus text exposition format.
+func (h *Handler) Metrics(c *gin.Context) {
+	stats := h.BrandCache.Stats()
+	var b strings.Builder
+	metric := func(name, kind, help string, value any) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+	}
+	metric("appdrop_brand_cache_hits_total", "counter", "Brand resolutions served from the cache.", stats.Hits)
+	metric("appdrop_brand_cache_misses_total", "counter", "Brand resolutions that went to the store.", stats.Misses)
+	metric("appdrop_brand_cache_evictions_total", "counter", "Entries evicted to stay within capacity.", stats.Evictions)
+	metric("appdrop_brand_cache_entries", "gauge", "Entries currently cached.", stats.Size)
+	metric("appdrop_brand_cache_capacity", "gauge", "Maximum number of cached entries.", stats.Capacity)
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
@@ -1,19 +1,23 @@
 package handlers
 
 import (
-	"APPDROP/db"
 	"APPDROP/models"
+	"APPDROP/store"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
-func CreatePages(c *gin.Context) {
+// CreatePages creates a draft page. With ?template=<id> the page starts with the template's widgets,
+// created in the same transaction as the page.
+func (h *Handler) CreatePages(c *gin.Context) {
 	brandID, ok := getBrandID(c)
 	if !ok {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
@@ -33,25 +37,50 @@ func CreatePages(c *gin.Context) {
 		return
 	}
 	page.BrandID = brandID
-	var existing models.Page
-	if err := db.DB.Where("route = ? AND brand_id = ?", page.Route, brandID).First(&existing).Error; err == nil {
+	page.Status = models.PageStatusDraft
+	page.PublishedAt = nil
+	page.Widgets = nil
+	ctx := c.Request.Context()
+	var widgets []models.Widget
+	if templateID := c.Query("template"); templateID != "" {
+		template, ok := h.lookupPageTemplate(c, brandID, templateID)
+		if !ok {
+			return
+		}
+		var errs []FieldError
+		if widgets, errs = h.templateWidgets(ctx, brandID, template.Widgets); len(errs) > 0 {
+			RespondValidationErrors(c, "Page template no longer fits its widget types", errs)
+			return
+		}
+	}
+	if _, err := h.Store.Pages().GetByRoute(ctx, brandID, page.Route); err == nil {
 		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "Page route already exists")
 		return
 	}
 	if page.IsHome {
-		var homePage models.Page
-		if err := db.DB.Where("is_home = true AND brand_id = ?", brandID).First(&homePage).Error; err == nil {
+		if _, err := h.Store.Pages().GetHome(ctx, brandID); err == nil {
 			RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "home page already exists")
 			return
 		}
 	}
-	if err := db.DB.Create(&page).Error; err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-			RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "Page route already exists for this brand")
-			return
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Pages().Create(ctx, &page); err != nil {
+			return err
+		}
+		for i := range widgets {
+			widgets[i].PageID = page.ID
+			if err := tx.Widgets().Create(ctx, &widgets[i]); err != nil {
+				return err
+			}
+		}
+		page.Widgets = widgets
+		if _, err := recordRevision(ctx, tx, brandID, page.ID, currentUserID(c), models.RevisionPageCreated); err != nil {
+			return err
 		}
-		if strings.Contains(err.Error(), "23505") || strings.Contains(err.Error(), "duplicate key") {
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditCreate, EntityType: models.AuditEntityPage, EntityID: page.ID}, nil, page)
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
 			RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "Page route already exists for this brand")
 			return
 		}
@@ -61,17 +90,18 @@ func CreatePages(c *gin.Context) {
 	c.JSON(http.StatusCreated, page)
 }
 
-func GetPages(c *gin.Context) {
+func (h *Handler) GetPages(c *gin.Context) {
 	brandID, ok := getBrandID(c)
 	if !ok {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
 		return
 	}
+	ctx := c.Request.Context()
 	pageParam := c.Query("page")
 	limitParam := c.Query("limit")
 	if pageParam == "" && limitParam == "" {
-		var pages []models.Page
-		if err := db.DB.Where("brand_id = ?", brandID).Find(&pages).Error; err != nil {
+		pages, err := h.Store.Pages().List(ctx, brandID, store.PageListOptions{})
+		if err != nil {
 			RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch pages")
 			return
 		}
@@ -94,13 +124,13 @@ func GetPages(c *gin.Context) {
 		}
 	}
 	offset := (page - 1) * limit
-	var total int64
-	if err := db.DB.Model(&models.Page{}).Where("brand_id = ?", brandID).Count(&total).Error; err != nil {
+	total, err := h.Store.Pages().Count(ctx, brandID)
+	if err != nil {
 		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to count pages")
 		return
 	}
-	var pages []models.Page
-	if err := db.DB.Where("brand_id = ?", brandID).Order("created_at ASC").Offset(offset).Limit(limit).Find(&pages).Error; err != nil {
+	pages, err := h.Store.Pages().List(ctx, brandID, store.PageListOptions{Offset: offset, Limit: limit})
+	if err != nil {
 		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch pages")
 		return
 	}
@@ -112,7 +142,7 @@ func GetPages(c *gin.Context) {
 	})
 }
 
-func GetPageByID(c *gin.Context) {
+func (h *Handler) GetPageByID(c *gin.Context) {
 	brandID, ok := getBrandID(c)
 	if !ok {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
@@ -125,27 +155,27 @@ func GetPageByID(c *gin.Context) {
 		return
 	}
 	widgetTypeFilter := c.Query("widget_type")
+	ctx := c.Request.Context()
 
-	if widgetTypeFilter != "" && !IsAllowedWidgetType(widgetTypeFilter) {
+	if widgetTypeFilter != "" && !h.IsAllowedWidgetType(ctx, brandID, widgetTypeFilter) {
 		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid widget_type filter")
 		return
 	}
 
-	var page models.Page
-	query := db.DB
-	if widgetTypeFilter != "" {
-		query = query.Preload("Widgets", "type = ?", widgetTypeFilter)
-	} else {
-		query = query.Preload("Widgets")
-	}
-	if err := query.Where("brand_id = ?", brandID).First(&page, "id = ?", pageID).Error; err != nil {
+	page, err := h.Store.Pages().Get(ctx, brandID, pageID)
+	if err != nil {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
 		return
 	}
+	page.Widgets, err = h.Store.Widgets().ListByPage(ctx, page.ID, widgetTypeFilter)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch widgets")
+		return
+	}
 	c.JSON(http.StatusOK, page)
 }
 
-func UpdatePage(c *gin.Context) {
+func (h *Handler) UpdatePage(c *gin.Context) {
 	brandID, ok := getBrandID(c)
 	if !ok {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
@@ -156,11 +186,13 @@ func UpdatePage(c *gin.Context) {
 		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid page ID")
 		return
 	}
-	var page models.Page
-	if err := db.DB.Where("brand_id = ?", brandID).First(&page, "id = ?", pageID).Error; err != nil {
+	ctx := c.Request.Context()
+	page, err := h.Store.Pages().Get(ctx, brandID, pageID)
+	if err != nil {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
 		return
 	}
+	before := *page
 
 	var input struct {
 		Name   *string `json:"name"`
@@ -184,16 +216,14 @@ func UpdatePage(c *gin.Context) {
 			RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "page route is required")
 			return
 		}
-		var existing models.Page
-		if err := db.DB.Where("route = ? AND brand_id = ? AND id != ?", *input.Route, brandID, pageID).First(&existing).Error; err == nil {
+		if existing, err := h.Store.Pages().GetByRoute(ctx, brandID, *input.Route); err == nil && existing.ID != pageID {
 			RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "Page route already exists")
 			return
 		}
 		page.Route = *input.Route
 	}
 	if input.IsHome != nil && *input.IsHome {
-		var homePage models.Page
-		if err := db.DB.Where("is_home = true AND brand_id = ? AND id != ?", brandID, pageID).First(&homePage).Error; err == nil {
+		if homePage, err := h.Store.Pages().GetHome(ctx, brandID); err == nil && homePage.ID != pageID {
 			RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "home page already exists")
 			return
 		}
@@ -202,9 +232,119 @@ func UpdatePage(c *gin.Context) {
 		page.IsHome = false
 	}
 
-	if err := db.DB.Save(&page).Error; err != nil {
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Pages().Update(ctx, page); err != nil {
+			return err
+		}
+		if _, err := recordRevision(ctx, tx, brandID, page.ID, currentUserID(c), models.RevisionPageUpdated); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditUpdate, EntityType: models.AuditEntityPage, EntityID: page.ID}, before, page)
+	})
+	if err != nil {
 		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update page")
 		return
 	}
 	c.JSON(http.StatusOK, page)
 }
+
+var errDuplicateRouteTaken = errors.New("duplicate route taken")
+
+// maxCopyRouteAttempts bounds the search for a free "-copy" route.
+const maxCopyRouteAttempts = 100
+
+type DuplicatePageRequest struct {
+	Name  *string `json:"name"`
+	Route *string `json:"route"`
+}
+
+// DuplicatePage copies a page and all of its widgets into a new draft page. The body is optional: without a
+// route the copy gets the source route plus "-copy" (then "-copy-2", ...). The copy is never the home page.
+func (h *Handler) DuplicatePage(c *gin.Context) {
+	source, ok := h.findBrandPage(c)
+	if !ok {
+		return
+	}
+	var req DuplicatePageRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	page := models.Page{BrandID: source.BrandID, Name: source.Name + " (copy)", Status: models.PageStatusDraft}
+	if req.Name != nil {
+		if *req.Name == "" {
+			RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "page name is required")
+			return
+		}
+		page.Name = *req.Name
+	}
+	if req.Route != nil && *req.Route == "" {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "page route is required")
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		if req.Route != nil {
+			if _, err := tx.Pages().GetByRoute(ctx, page.BrandID, *req.Route); err == nil {
+				return errDuplicateRouteTaken
+			}
+			page.Route = *req.Route
+		} else {
+			route, err := freeCopyRoute(ctx, tx, page.BrandID, source.Route)
+			if err != nil {
+				return err
+			}
+			page.Route = route
+		}
+		if err := tx.Pages().Create(ctx, &page); err != nil {
+			return err
+		}
+		widgets, err := tx.Widgets().ListByPage(ctx, source.ID, "")
+		if err != nil {
+			return err
+		}
+		for i := range widgets {
+			widgets[i].ID = uuid.Nil
+			widgets[i].PageID = page.ID
+			if err := tx.Widgets().Create(ctx, &widgets[i]); err != nil {
+				return err
+			}
+		}
+		page.Widgets = widgets
+		if _, err := recordRevision(ctx, tx, page.BrandID, page.ID, currentUserID(c), models.RevisionPageDuplicated); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditCopy, EntityType: models.AuditEntityPage, EntityID: page.ID}, nil, page)
+	})
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, page)
+	case errors.Is(err, errDuplicateRouteTaken), errors.Is(err, store.ErrConflict):
+		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "Page route already exists")
+	default:
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to duplicate page")
+	}
+}
+
+// freeCopyRoute finds the first unused route of the form <route>-copy, <route>-copy-2, ...
+func freeCopyRoute(ctx context.Context, tx store.Store, brandID uuid.UUID, route string) (string, error) {
+	base := strings.TrimRight(route, "/") + "-copy"
+	if base == "-copy" {
+		base = "/copy"
+	}
+	for n := 1; n <= maxCopyRouteAttempts; n++ {
+		candidate := base
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, n)
+		}
+		_, err := tx.Pages().GetByRoute(ctx, brandID, candidate)
+		if errors.Is(err, store.ErrNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", errDuplicateRouteTaken
+}
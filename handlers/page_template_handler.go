@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"APPDROP/models"
+	"APPDROP/store"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	maxTemplateNameLength = 100
+	maxTemplateWidgets    = 50
+)
+
+type PageTemplateRequest struct {
+	Name        *string                 `json:"name"`
+	Description *string                 `json:"description"`
+	Widgets     []models.TemplateWidget `json:"widgets"`
+}
+
+// builtinTemplateID gives each global template an ID that stays the same across restarts and deployments.
+func builtinTemplateID(key string) uuid.UUID {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://appdrop.dev/page-templates/"+key))
+}
+
+// builtinPageTemplates returns the global templates. Like the built-in widget types they live in code, not in
+// page_templates: every API caller acts within one brand, so none may change what all brands share, and
+// changing a global template is a code change. Each call builds fresh values, so callers may modify them.
+func builtinPageTemplates() []models.PageTemplate {
+	templates := []models.PageTemplate{
+		{
+			ID:          builtinTemplateID("landing"),
+			Name:        "Landing page",
+			Description: "A hero banner, an introduction and featured products.",
+			Widgets: []models.TemplateWidget{
+				{Type: "banner", Config: map[string]interface{}{"image_url": "https://placehold.co/1200x400", "title": "Welcome"}},
+				{Type: "text", Config: map[string]interface{}{"content": "Tell customers what makes your brand special.", "align": "center"}},
+				{Type: "product_grid", Config: map[string]interface{}{"columns": 3.0, "title": "Featured products"}},
+			},
+		},
+		{
+			ID:          builtinTemplateID("collection"),
+			Name:        "Collection",
+			Description: "A title and a product grid.",
+			Widgets: []models.TemplateWidget{
+				{Type: "text", Config: map[string]interface{}{"content": "Collection", "align": "left"}},
+				{Type: "product_grid", Config: map[string]interface{}{"columns": 4.0}},
+			},
+		},
+		{
+			ID:          builtinTemplateID("article"),
+			Name:        "Article",
+			Description: "A cover image followed by text.",
+			Widgets: []models.TemplateWidget{
+				{Type: "image", Config: map[string]interface{}{"url": "https://placehold.co/1200x600", "alt": ""}},
+				{Type: "spacer", Config: map[string]interface{}{"height": 24.0}},
+				{Type: "text", Config: map[string]interface{}{"content": "Start writing here."}},
+			},
+		},
+	}
+	for i := range templates {
+		templates[i].Builtin = true
+	}
+	return templates
+}
+
+func builtinPageTemplate(id uuid.UUID) (*models.PageTemplate, bool) {
+	for _, t := range builtinPageTemplates() {
+		if t.ID == id {
+			return &t, true
+		}
+	}
+	return nil, false
+}
+
+// lookupPageTemplate resolves id to a global template or one of the brand's, responding 400/404 itself when it can't.
+func (h *Handler) lookupPageTemplate(c *gin.Context, brandID uuid.UUID, rawID string) (*models.PageTemplate, bool) {
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid page template ID")
+		return nil, false
+	}
+	if template, ok := builtinPageTemplate(id); ok {
+		return template, true
+	}
+	template, err := h.Store.PageTemplates().Get(c.Request.Context(), brandID, id)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Page template not found")
+		return nil, false
+	}
+	return template, true
+}
+
+// findBrandPageTemplate loads a template the brand may change; global templates are read-only.
+func (h *Handler) findBrandPageTemplate(c *gin.Context) (*models.PageTemplate, bool) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return nil, false
+	}
+	template, ok := h.lookupPageTemplate(c, brandID, c.Param("id"))
+	if !ok {
+		return nil, false
+	}
+	if template.Builtin {
+		RespondError(c, http.StatusForbidden, "FORBIDDEN", "Global page templates are built in and read-only; create a brand template instead")
+		return nil, false
+	}
+	return template, true
+}
+
+// templateWidgets turns a template's widgets into widgets for a new page, in template order, with each
+// config merged over its type's defaults. Unknown types and configs that don't fit their schema are
+// reported per widget, so a template is checked both when it is saved and when it is used, in case a
+// custom widget type changed in between.
+func (h *Handler) templateWidgets(ctx context.Context, brandID uuid.UUID, template []models.TemplateWidget) ([]models.Widget, []FieldError) {
+	widgets := make([]models.Widget, 0, len(template))
+	var errs []FieldError
+	for i, tw := range template {
+		path := fmt.Sprintf("widgets[%d]", i)
+		widgetType, ok := h.LookupWidgetType(ctx, brandID, tw.Type)
+		if !ok {
+			errs = append(errs, FieldError{Field: path + ".type", Message: "unknown widget type " + fmt.Sprintf("%q", tw.Type)})
+			continue
+		}
+		widget := models.Widget{Type: tw.Type, Position: i, Config: withDefaultConfig(tw.Config, widgetType.DefaultConfig)}
+		for _, e := range ValidateWidgetConfig(widgetType.Schema, widget.Config) {
+			errs = append(errs, FieldError{Field: path + ".config." + e.Field, Message: e.Message})
+		}
+		widgets = append(widgets, widget)
+	}
+	return widgets, errs
+}
+
+// applyPageTemplateRequest copies the request onto template and validates the result, responding itself when it is invalid.
+func (h *Handler) applyPageTemplateRequest(c *gin.Context, template *models.PageTemplate, req PageTemplateRequest) bool {
+	if req.Name != nil {
+		template.Name = strings.TrimSpace(*req.Name)
+	}
+	if template.Name == "" || len(template.Name) > maxTemplateNameLength {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("template name is required, at most %d characters", maxTemplateNameLength))
+		return false
+	}
+	if req.Description != nil {
+		template.Description = *req.Description
+	}
+	if req.Widgets != nil {
+		template.Widgets = req.Widgets
+	}
+	if template.Widgets == nil {
+		template.Widgets = []models.TemplateWidget{}
+	}
+	if len(template.Widgets) > maxTemplateWidgets {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("a template can have at most %d widgets", maxTemplateWidgets))
+		return false
+	}
+	if _, errs := h.templateWidgets(c.Request.Context(), template.BrandID, template.Widgets); len(errs) > 0 {
+		RespondValidationErrors(c, "Invalid template widgets", errs)
+		return false
+	}
+	return true
+}
+
+// ListPageTemplates returns the global templates followed by the brand's own, ordered by name.
+func (h *Handler) ListPageTemplates(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	custom, err := h.Store.PageTemplates().List(c.Request.Context(), brandID)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch page templates")
+		return
+	}
+	c.JSON(http.StatusOK, append(builtinPageTemplates(), custom...))
+}
+
+func (h *Handler) GetPageTemplate(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	template, ok := h.lookupPageTemplate(c, brandID, c.Param("id"))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+func (h *Handler) CreatePageTemplate(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	var req PageTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	template := models.PageTemplate{BrandID: brandID}
+	if !h.applyPageTemplateRequest(c, &template, req) {
+		return
+	}
+	ctx := c.Request.Context()
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.PageTemplates().Create(ctx, &template); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditCreate, EntityType: models.AuditEntityPageTemplate, EntityID: template.ID}, nil, template)
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "page template name already exists")
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to create page template")
+		return
+	}
+	c.JSON(http.StatusCreated, template)
+}
+
+func (h *Handler) UpdatePageTemplate(c *gin.Context) {
+	template, ok := h.findBrandPageTemplate(c)
+	if !ok {
+		return
+	}
+	var req PageTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	before := *template
+	if !h.applyPageTemplateRequest(c, template, req) {
+		return
+	}
+	ctx := c.Request.Context()
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.PageTemplates().Update(ctx, template); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditUpdate, EntityType: models.AuditEntityPageTemplate, EntityID: template.ID}, before, template)
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "page template name already exists")
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update page template")
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// DeletePageTemplate removes a brand template. Pages created from it are independent copies and stay as they are.
+func (h *Handler) DeletePageTemplate(c *gin.Context) {
+	template, ok := h.findBrandPageTemplate(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.PageTemplates().Delete(ctx, template.BrandID, template.ID); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditDelete, EntityType: models.AuditEntityPageTemplate, EntityID: template.ID}, template, nil)
+	})
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete page template")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBuiltinPageTemplatesAreValid(t *testing.T) {
+	h := &Handler{}
+	seen := map[uuid.UUID]bool{}
+	for _, template := range builtinPageTemplates() {
+		if seen[template.ID] {
+			t.Errorf("%s: duplicate ID %s", template.Name, template.ID)
+		}
+		seen[template.ID] = true
+		if _, errs := h.templateWidgets(context.Background(), uuid.Nil, template.Widgets); len(errs) > 0 {
+			t.Errorf("%s: %v", template.Name, errs)
+		}
+	}
+}
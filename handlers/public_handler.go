@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"APPDROP/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Public payloads only expose what the storefront app needs to render; drafts and account data never leave.
+type PublicBrand struct {
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Logo   string    `json:"logo"`
+	Domain string    `json:"domain"`
+}
+
+type PublicWidget struct {
+	ID       uuid.UUID              `json:"id"`
+	Type     string                 `json:"type"`
+	Position int                    `json:"position"`
+	Config   map[string]interface{} `json:"config,omitempty"`
+}
+
+type PublicPage struct {
+	ID          uuid.UUID      `json:"id"`
+	Name        string         `json:"name"`
+	Route       string         `json:"route"`
+	IsHome      bool           `json:"is_home"`
+	Widgets     []PublicWidget `json:"widgets"`
+	PublishedAt time.Time      `json:"published_at"`
+}
+
+type PublicPageLink struct {
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Route  string    `json:"route"`
+	IsHome bool      `json:"is_home"`
+}
+
+type AppManifest struct {
+	Brand PublicBrand      `json:"brand"`
+	Home  *PublicPage      `json:"home"`
+	Pages []PublicPageLink `json:"pages"`
+}
+
+const publicCacheControl = "public, max-age=60"
+
+func newPublicPage(p models.PublishedPage) PublicPage {
+	widgets := make([]PublicWidget, 0, len(p.Snapshot.Widgets))
+	for _, w := range p.Snapshot.Widgets {
+		widgets = append(widgets, PublicWidget{
+			ID:       w.ID,
+			Type:     w.Type,
+			Position: w.Position,
+			Config:   w.Config,
+		})
+	}
+	return PublicPage{
+		ID:          p.PageID,
+		Name:        p.Snapshot.Name,
+		Route:       p.Route,
+		IsHome:      p.IsHome,
+		Widgets:     widgets,
+		PublishedAt: p.PublishedAt,
+	}
+}
+
+func (h *Handler) GetPublicApp(c *gin.Context) {
+	brand, ok := getBrandFromContext(c)
+	if !ok || brand == nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	published, err := h.Store.PublishedPages().List(c.Request.Context(), brand.ID)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch app")
+		return
+	}
+
+	manifest := AppManifest{
+		Brand: PublicBrand{
+			ID:     brand.ID,
+			Name:   brand.Name,
+			Logo:   brand.Logo,
+			Domain: brand.Domain,
+		},
+		Pages: make([]PublicPageLink, 0, len(published)),
+	}
+	for _, p := range published {
+		manifest.Pages = append(manifest.Pages, PublicPageLink{
+			ID:     p.PageID,
+			Name:   p.Snapshot.Name,
+			Route:  p.Route,
+			IsHome: p.IsHome,
+		})
+		if p.IsHome {
+			home := newPublicPage(p)
+			manifest.Home = &home
+		}
+	}
+	c.Header("Cache-Control", publicCacheControl)
+	c.JSON(http.StatusOK, manifest)
+}
+
+func (h *Handler) GetPublicPage(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	route := c.Param("route")
+	published, err := h.Store.PublishedPages().GetByRoute(c.Request.Context(), brandID, route)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+		return
+	}
+	c.Header("Cache-Control", publicCacheControl)
+	c.JSON(http.StatusOK, newPublicPage(*published))
+}
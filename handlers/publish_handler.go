@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"APPDROP/models"
+	"APPDROP/store"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	errPublishedRouteTaken = errors.New("published route taken")
+	errPublishedHomeTaken  = errors.New("published home taken")
+)
+
+func (h *Handler) PublishPage(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	pageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid page ID")
+		return
+	}
+
+	ctx := c.Request.Context()
+	var published models.PublishedPage
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		page, err := tx.Pages().Get(ctx, brandID, pageID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Pages().Lock(ctx, page.ID); err != nil {
+			return err
+		}
+		widgets, err := tx.Widgets().ListByPage(ctx, page.ID, "")
+		if err != nil {
+			return err
+		}
+
+		if existing, err := tx.PublishedPages().GetByRoute(ctx, brandID, page.Route); err == nil && existing.PageID != pageID {
+			return errPublishedRouteTaken
+		}
+		if page.IsHome {
+			if existing, err := tx.PublishedPages().GetHome(ctx, brandID); err == nil && existing.PageID != pageID {
+				return errPublishedHomeTaken
+			}
+		}
+
+		now := time.Now()
+		published = models.PublishedPage{
+			PageID:              page.ID,
+			BrandID:             brandID,
+			Route:               page.Route,
+			IsHome:              page.IsHome,
+			Snapshot:            models.NewPageSnapshot(*page, widgets),
+			PublishedBy:         currentUserID(c),
+			PublishedByAPIKeyID: currentAPIKeyID(c),
+			PublishedAt:         now,
+		}
+		if err := tx.PublishedPages().Save(ctx, &published); err != nil {
+			return err
+		}
+		before := *page
+		page.Status = models.PageStatusPublished
+		page.PublishedAt = &now
+		if err := tx.Pages().Update(ctx, page); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditPublish, EntityType: models.AuditEntityPage, EntityID: page.ID}, before, page)
+	})
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, published)
+	case errors.Is(err, store.ErrNotFound):
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+	case errors.Is(err, errPublishedRouteTaken):
+		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "Another published page already uses this route")
+	case errors.Is(err, errPublishedHomeTaken):
+		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "Another published page is already the home page")
+	default:
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to publish page")
+	}
+}
+
+func (h *Handler) UnpublishPage(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	pageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid page ID")
+		return
+	}
+	ctx := c.Request.Context()
+	page, err := h.Store.Pages().Get(ctx, brandID, pageID)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+		return
+	}
+
+	before := *page
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.PublishedPages().Delete(ctx, page.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		page.Status = models.PageStatusDraft
+		page.PublishedAt = nil
+		if err := tx.Pages().Update(ctx, page); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditUnpublish, EntityType: models.AuditEntityPage, EntityID: page.ID}, before, page)
+	})
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to unpublish page")
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *Handler) GetPublishedPage(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	pageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid page ID")
+		return
+	}
+	published, err := h.Store.PublishedPages().Get(c.Request.Context(), brandID, pageID)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Page is not published")
+		return
+	}
+	c.JSON(http.StatusOK, published)
+}
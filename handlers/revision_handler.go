@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"APPDROP/models"
+	"APPDROP/store"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	errRevisionRouteTaken = errors.New("revision route taken")
+	errRevisionHomeTaken  = errors.New("revision home taken")
+)
+
+type RevisionSummary struct {
+	ID        uuid.UUID  `json:"id"`
+	Number    int        `json:"number"`
+	Action    string     `json:"action"`
+	UserID    *uuid.UUID `json:"user_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// recordRevision snapshots the page's current state as its next revision.
+// It must run inside the transaction that made the change; the page row is locked so numbers stay sequential.
+func recordRevision(ctx context.Context, tx store.Store, brandID, pageID uuid.UUID, userID *uuid.UUID, action string) (*models.PageRevision, error) {
+	if err := tx.Pages().Lock(ctx, pageID); err != nil {
+		return nil, err
+	}
+	page, err := tx.Pages().Get(ctx, brandID, pageID)
+	if err != nil {
+		return nil, err
+	}
+	widgets, err := tx.Widgets().ListByPage(ctx, pageID, "")
+	if err != nil {
+		return nil, err
+	}
+	last, err := tx.Revisions().LatestNumber(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+	revision := models.PageRevision{
+		PageID:   pageID,
+		BrandID:  page.BrandID,
+		Number:   last + 1,
+		Action:   action,
+		UserID:   userID,
+		Snapshot: models.NewPageSnapshot(*page, widgets),
+	}
+	if err := tx.Revisions().Create(ctx, &revision); err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// findBrandPage loads a page owned by the current brand, responding 400/404 itself when it can't.
+func (h *Handler) findBrandPage(c *gin.Context) (*models.Page, bool) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return nil, false
+	}
+	pageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid page ID")
+		return nil, false
+	}
+	page, err := h.Store.Pages().Get(c.Request.Context(), brandID, pageID)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+		return nil, false
+	}
+	return page, true
+}
+
+func parseRevisionNumber(c *gin.Context) (int, bool) {
+	number, err := strconv.Atoi(c.Param("rev"))
+	if err != nil || number < 1 {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid revision number")
+		return 0, false
+	}
+	return number, true
+}
+
+func (h *Handler) ListPageRevisions(c *gin.Context) {
+	page, ok := h.findBrandPage(c)
+	if !ok {
+		return
+	}
+	revisions, err := h.Store.Revisions().List(c.Request.Context(), page.ID)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch revisions")
+		return
+	}
+	summaries := make([]RevisionSummary, 0, len(revisions))
+	for _, r := range revisions {
+		summaries = append(summaries, RevisionSummary{
+			ID:        r.ID,
+			Number:    r.Number,
+			Action:    r.Action,
+			UserID:    r.UserID,
+			CreatedAt: r.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, summaries)
+}
+
+func (h *Handler) GetPageRevision(c *gin.Context) {
+	page, ok := h.findBrandPage(c)
+	if !ok {
+		return
+	}
+	number, ok := parseRevisionNumber(c)
+	if !ok {
+		return
+	}
+	revision, err := h.Store.Revisions().Get(c.Request.Context(), page.ID, number)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Revision not found")
+		return
+	}
+	c.JSON(http.StatusOK, revision)
+}
+
+func (h *Handler) RestorePageRevision(c *gin.Context) {
+	page, ok := h.findBrandPage(c)
+	if !ok {
+		return
+	}
+	number, ok := parseRevisionNumber(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	revision, err := h.Store.Revisions().Get(ctx, page.ID, number)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Revision not found")
+		return
+	}
+	snapshot := revision.Snapshot
+	before := *page
+
+	var restored *models.PageRevision
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if existing, err := tx.Pages().GetByRoute(ctx, page.BrandID, snapshot.Route); err == nil && existing.ID != page.ID {
+			return errRevisionRouteTaken
+		}
+		if snapshot.IsHome {
+			if existing, err := tx.Pages().GetHome(ctx, page.BrandID); err == nil && existing.ID != page.ID {
+				return errRevisionHomeTaken
+			}
+		}
+
+		page.Name = snapshot.Name
+		page.Route = snapshot.Route
+		page.IsHome = snapshot.IsHome
+		if err := tx.Pages().Update(ctx, page); err != nil {
+			return err
+		}
+		if err := tx.Widgets().DeleteByPage(ctx, page.ID); err != nil {
+			return err
+		}
+		for _, w := range snapshot.Widgets {
+			// A widget moved to another page since the revision keeps its ID there; the restored copy gets a new one.
+			if _, err := tx.Widgets().Get(ctx, w.ID); err == nil {
+				w.ID = uuid.Nil
+			}
+			w.PageID = page.ID
+			if err := tx.Widgets().Create(ctx, &w); err != nil {
+				return err
+			}
+		}
+		var err error
+		restored, err = recordRevision(ctx, tx, page.BrandID, page.ID, currentUserID(c), models.RevisionRestored)
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditRestore, EntityType: models.AuditEntityPage, EntityID: page.ID}, before, page)
+	})
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, restored)
+	case errors.Is(err, errRevisionRouteTaken):
+		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "Another page already uses this revision's route")
+	case errors.Is(err, errRevisionHomeTaken):
+		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "home page already exists")
+	default:
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to restore revision")
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"APPDROP/auth"
+	"APPDROP/middlewares"
+	"APPDROP/models"
+	"APPDROP/store"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SessionResponse marks which of the listed sessions made the request.
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// sessionTokens are the credentials handed to the client for one access token lifetime.
+type sessionTokens struct {
+	access, refresh, csrf string
+	sessionID             uuid.UUID
+	refreshExpiresAt      time.Time
+}
+
+// startSession records a new server-side session for user and returns its tokens, for the caller to
+// deliver as cookies or in the response body.
+func (h *Handler) startSession(c *gin.Context, user *models.User) (sessionTokens, error) {
+	session := models.Session{
+		ID:        uuid.New(),
+		BrandID:   user.BrandID,
+		UserID:    user.ID,
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+	tokens, err := issueTokens(&session)
+	if err != nil {
+		return sessionTokens{}, err
+	}
+	if err := h.Store.Sessions().Create(c.Request.Context(), &session); err != nil {
+		return sessionTokens{}, err
+	}
+	return tokens, nil
+}
+
+// issueTokens gives the session a new access token, refresh token and CSRF token; the caller persists session.
+func issueTokens(session *models.Session) (sessionTokens, error) {
+	refresh, hash, err := auth.NewRefreshToken(session.ID)
+	if err != nil {
+		return sessionTokens{}, err
+	}
+	csrf, csrfHash, err := auth.NewCSRFToken()
+	if err != nil {
+		return sessionTokens{}, err
+	}
+	access, claims, err := auth.CreateToken(session.BrandID, session.UserID.String(), session.ID, csrfHash, auth.AccessTokenDuration)
+	if err != nil {
+		return sessionTokens{}, err
+	}
+	session.RefreshTokenHash = hash
+	session.AccessTokenID = claims.ID
+	session.CSRFTokenHash = csrfHash
+	session.ExpiresAt = time.Now().Add(auth.RefreshTokenDuration)
+	return sessionTokens{access: access, refresh: refresh, csrf: csrf, sessionID: session.ID, refreshExpiresAt: session.ExpiresAt}, nil
+}
+
+func setSessionCookies(c *gin.Context, tokens sessionTokens) {
+	auth.SetSessionCookie(c.Writer, tokens.access, c.Request.Host, false)
+	auth.SetRefreshCookie(c.Writer, tokens.refresh, c.Request.Host, false)
+	auth.SetCSRFCookie(c.Writer, tokens.csrf, c.Request.Host, false)
+}
+
+// revokeSession ends the session and puts its latest access token on the revocation list.
+func revokeSession(ctx context.Context, s store.Store, session *models.Session) error {
+	if session.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	if err := s.Sessions().Update(ctx, session); err != nil {
+		return err
+	}
+	return s.Sessions().RevokeToken(ctx, session.AccessTokenID, now.Add(auth.AccessTokenDuration))
+}
+
+// revokeUserSessions signs user out of every active session but keep, which is uuid.Nil to keep none. Used
+// when the user's password or role changes, so tokens issued before it stop working.
+func revokeUserSessions(ctx context.Context, s store.Store, user *models.User, keep uuid.UUID) error {
+	sessions, err := s.Sessions().ListActive(ctx, user.BrandID, time.Now())
+	if err != nil {
+		return err
+	}
+	for i := range sessions {
+		if sessions[i].UserID == user.ID && sessions[i].ID != keep {
+			if err := revokeSession(ctx, s, &sessions[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Refresh exchanges the refresh cookie for a new access token and a new refresh token. Presenting a refresh
+// token that has already been rotated out means it was copied, so the whole session is revoked.
+func (h *Handler) Refresh(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	token, _ := c.Cookie(auth.RefreshCookieName())
+	tokens, ok := h.refreshSession(c, brandID, token, true)
+	if !ok {
+		return
+	}
+	setSessionCookies(c, tokens)
+	c.JSON(http.StatusOK, gin.H{"message": "ok", "expires_at": tokens.refreshExpiresAt, "csrf_token": tokens.csrf})
+}
+
+var (
+	errSessionEnded    = errors.New("session expired or revoked")
+	errSessionUserGone = errors.New("session user no longer exists")
+	errSessionCSRF     = errors.New("missing or invalid CSRF token")
+)
+
+// refreshSession rotates the session's tokens. Presenting a refresh token that has already been rotated out
+// revokes the session; the session is locked first, so of concurrent refreshes with one token only the first
+// rotates it and the rest count as reuse. A token from the refresh cookie must come with the session's CSRF
+// token, since a browser sends the cookie on cross-site requests too. On failure it has already responded,
+// clearing the session cookies if fromCookie is set.
+func (h *Handler) refreshSession(c *gin.Context, brandID uuid.UUID, token string, fromCookie bool) (sessionTokens, bool) {
+	fail := func(status int, code, msg string) (sessionTokens, bool) {
+		if fromCookie && status == http.StatusUnauthorized {
+			auth.ClearSessionCookie(c.Writer, c.Request.Host, false)
+		}
+		RespondError(c, status, code, msg)
+		return sessionTokens{}, false
+	}
+	sessionID, ok := auth.ParseRefreshToken(token)
+	if !ok {
+		RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "Missing or invalid refresh token")
+		return sessionTokens{}, false
+	}
+	ctx := c.Request.Context()
+	var tokens sessionTokens
+	reused := false
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Sessions().Lock(ctx, sessionID); err != nil {
+			return err
+		}
+		session, err := tx.Sessions().Get(ctx, brandID, sessionID)
+		if err != nil {
+			return err
+		}
+		if !session.Active(time.Now()) {
+			return errSessionEnded
+		}
+		if !auth.RefreshTokenMatches(token, session.RefreshTokenHash) {
+			reused = true
+			return revokeSession(ctx, tx, session)
+		}
+		if fromCookie && !auth.CSRFTokenMatches(c.GetHeader(auth.CSRFHeader), session.CSRFTokenHash) {
+			return errSessionCSRF
+		}
+		if _, err := tx.Users().Get(ctx, brandID, session.UserID); err != nil {
+			return errSessionUserGone
+		}
+		previousAccess := session.AccessTokenID
+		if tokens, err = issueTokens(session); err != nil {
+			return err
+		}
+		now := time.Now()
+		session.RefreshedAt = &now
+		if err := tx.Sessions().Update(ctx, session); err != nil {
+			return err
+		}
+		return tx.Sessions().RevokeToken(ctx, previousAccess, now.Add(auth.AccessTokenDuration))
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound), errors.Is(err, errSessionEnded):
+		return fail(http.StatusUnauthorized, "UNAUTHORIZED", "Session expired or revoked")
+	case errors.Is(err, errSessionCSRF):
+		return fail(http.StatusForbidden, "FORBIDDEN", "Missing or invalid CSRF token")
+	case errors.Is(err, errSessionUserGone):
+		RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "User for this session no longer exists")
+		return sessionTokens{}, false
+	case err != nil:
+		return fail(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to refresh session")
+	case reused:
+		return fail(http.StatusUnauthorized, "UNAUTHORIZED", "Refresh token reuse detected; session revoked")
+	}
+	return tokens, true
+}
+
+// ListSessions returns active sessions: all of the brand's for user managers, otherwise the caller's own.
+func (h *Handler) ListSessions(c *gin.Context) {
+	user, ok := sessionUser(c)
+	if !ok {
+		return
+	}
+	sessions, err := h.Store.Sessions().ListActive(c.Request.Context(), user.BrandID, time.Now())
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch sessions")
+		return
+	}
+	current, _ := currentSessionID(c)
+	all := middlewares.HasPermission(user.Role, middlewares.PermUsersWrite)
+	resp := make([]SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		if all || s.UserID == user.ID {
+			resp = append(resp, SessionResponse{Session: s, Current: s.ID == current})
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RevokeSession signs a session out everywhere. Users may revoke their own sessions; user managers any in the brand.
+func (h *Handler) RevokeSession(c *gin.Context) {
+	user, ok := sessionUser(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid session ID")
+		return
+	}
+	ctx := c.Request.Context()
+	session, err := h.Store.Sessions().Get(ctx, user.BrandID, id)
+	if err != nil || (session.UserID != user.ID && !middlewares.HasPermission(user.Role, middlewares.PermUsersWrite)) {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Session not found")
+		return
+	}
+	if err := revokeSession(ctx, h.Store, session); err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to revoke session")
+		return
+	}
+	if current, _ := currentSessionID(c); current == session.ID {
+		auth.ClearSessionCookie(c.Writer, c.Request.Host, false)
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"APPDROP/auth"
+	"APPDROP/models"
+	"APPDROP/oidc"
+	"APPDROP/store"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UpdateSSORequest struct {
+	Issuer   string `json:"issuer"`
+	ClientID string `json:"client_id"`
+	// ClientSecret is kept when omitted; send "" for a public client that relies on PKCE alone.
+	ClientSecret *string `json:"client_secret"`
+	RedirectURL  string  `json:"redirect_url"`
+	DefaultRole  string  `json:"default_role"`
+}
+
+// GetSSO returns the brand's OpenID Connect settings, without the client secret.
+func (h *Handler) GetSSO(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	provider, err := h.Store.SSO().GetProvider(c.Request.Context(), brandID)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Single sign-on is not configured")
+		return
+	}
+	c.JSON(http.StatusOK, provider)
+}
+
+// UpdateSSO configures single sign-on. The issuer's discovery document is fetched first, so a typo
+// fails here rather than at the first login.
+func (h *Handler) UpdateSSO(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	var req UpdateSSORequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	req.Issuer = strings.TrimSpace(req.Issuer)
+	if err := oidc.ValidateIssuer(req.Issuer); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+	if strings.TrimSpace(req.ClientID) == "" {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "client_id is required")
+		return
+	}
+	if u, err := url.Parse(req.RedirectURL); err != nil || !u.IsAbs() || u.Host == "" || !strings.HasSuffix(u.Path, "/sso/oidc/callback") {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "redirect_url must be an absolute URL ending in /sso/oidc/callback")
+		return
+	}
+	if req.DefaultRole != "" && !models.IsValidRole(req.DefaultRole) {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "default_role must be owner, editor or viewer")
+		return
+	}
+
+	ctx := c.Request.Context()
+	provider := models.OIDCProvider{BrandID: brandID}
+	action := models.AuditCreate
+	var before *models.OIDCProvider
+	if existing, err := h.Store.SSO().GetProvider(ctx, brandID); err == nil {
+		provider, before, action = *existing, existing, models.AuditUpdate
+	}
+	if _, err := h.OIDC.Discover(ctx, req.Issuer); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Could not load the issuer's discovery document: "+err.Error())
+		return
+	}
+	provider.Issuer = req.Issuer
+	provider.ClientID = strings.TrimSpace(req.ClientID)
+	if req.ClientSecret != nil {
+		provider.ClientSecret = *req.ClientSecret
+	}
+	provider.RedirectURL = req.RedirectURL
+	provider.DefaultRole = req.DefaultRole
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.SSO().SaveProvider(ctx, &provider); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: action, EntityType: models.AuditEntitySSO, EntityID: brandID}, before, provider)
+	})
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save single sign-on settings")
+		return
+	}
+	c.JSON(http.StatusOK, provider)
+}
+
+// DeleteSSO turns single sign-on off. Linked identities are kept, so turning it back on with the
+// same issuer signs the same people into the same users.
+func (h *Handler) DeleteSSO(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	ctx := c.Request.Context()
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		provider, err := tx.SSO().GetProvider(ctx, brandID)
+		if err != nil {
+			return err
+		}
+		if err := tx.SSO().DeleteProvider(ctx, brandID); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditDelete, EntityType: models.AuditEntitySSO, EntityID: brandID}, provider, nil)
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			RespondError(c, http.StatusNotFound, "NOT_FOUND", "Single sign-on is not configured")
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to remove single sign-on settings")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SSOLogin starts an authorization code login with PKCE by redirecting the browser to the identity provider.
+func (h *Handler) SSOLogin(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	ctx := c.Request.Context()
+	provider, err := h.Store.SSO().GetProvider(ctx, brandID)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Single sign-on is not configured")
+		return
+	}
+	idp, err := h.OIDC.Discover(ctx, provider.Issuer)
+	if err != nil {
+		c.Error(fmt.Errorf("oidc discovery: %w", err))
+		RespondError(c, http.StatusBadGateway, "INTERNAL_ERROR", "Identity provider is unavailable")
+		return
+	}
+	state := auth.OIDCState{BrandID: brandID}
+	var challenge string
+	state.Verifier, challenge, err = oidc.NewPKCE()
+	if err == nil {
+		state.State, err = oidc.NewState()
+	}
+	if err == nil {
+		state.Nonce, err = oidc.NewState()
+	}
+	var cookie string
+	if err == nil {
+		cookie, err = auth.CreateOIDCState(state)
+	}
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to start sign-in")
+		return
+	}
+	auth.SetOIDCStateCookie(c.Writer, cookie, c.Request.Host, false)
+	c.Redirect(http.StatusFound, idp.AuthCodeURL(oidcConfig(provider), state.State, state.Nonce, challenge))
+}
+
+// SSOCallback finishes the login: it checks the state cookie, redeems the code, validates the ID token,
+// and starts a normal session for the brand user the identity maps to. Local two-factor authentication
+// is not asked for; the identity provider is responsible for it.
+func (h *Handler) SSOCallback(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	cookie, _ := c.Cookie(auth.OIDCStateCookieName())
+	auth.ClearOIDCStateCookie(c.Writer, c.Request.Host, false)
+	if c.Query("error") != "" {
+		RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "Sign-in was denied by the identity provider")
+		return
+	}
+	state, err := auth.ParseOIDCState(cookie)
+	if err != nil || state.BrandID != brandID ||
+		subtle.ConstantTimeCompare([]byte(state.State), []byte(c.Query("state"))) != 1 {
+		RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "Sign-in expired or was started in another browser; try again")
+		return
+	}
+
+	ctx := c.Request.Context()
+	provider, err := h.Store.SSO().GetProvider(ctx, brandID)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Single sign-on is not configured")
+		return
+	}
+	idp, err := h.OIDC.Discover(ctx, provider.Issuer)
+	if err != nil {
+		c.Error(fmt.Errorf("oidc discovery: %w", err))
+		RespondError(c, http.StatusBadGateway, "INTERNAL_ERROR", "Identity provider is unavailable")
+		return
+	}
+	raw, err := h.OIDC.Exchange(ctx, idp, oidcConfig(provider), c.Query("code"), state.Verifier)
+	var id *oidc.IDToken
+	if err == nil {
+		id, err = h.OIDC.Verify(ctx, idp, provider.ClientID, raw, state.Nonce)
+	}
+	if err != nil {
+		c.Error(fmt.Errorf("oidc callback: %w", err))
+		RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "Sign-in with the identity provider failed")
+		return
+	}
+
+	user, ok := h.ssoUser(c, provider, id)
+	if !ok {
+		return
+	}
+	tokens, err := h.startSession(c, user)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create session")
+		return
+	}
+	setSessionCookies(c, tokens)
+	if h.AppURL != "" {
+		c.Redirect(http.StatusSeeOther, h.AppURL)
+		return
+	}
+	c.JSON(http.StatusOK, loginResponse(user, tokens.csrf))
+}
+
+// ssoUser maps a validated identity to a brand user: first through a linked identity, then by the
+// verified email (linking it for next time), and finally by creating a user with the provider's default role.
+// It responds itself when there is no user to sign in.
+func (h *Handler) ssoUser(c *gin.Context, provider *models.OIDCProvider, id *oidc.IDToken) (*models.User, bool) {
+	ctx := c.Request.Context()
+	brandID := provider.BrandID
+	if identity, err := h.Store.SSO().GetIdentity(ctx, brandID, id.Issuer, id.Subject); err == nil {
+		user, err := h.Store.Users().Get(ctx, brandID, identity.UserID)
+		if err != nil {
+			RespondError(c, http.StatusForbidden, "FORBIDDEN", "The linked user no longer exists")
+			return nil, false
+		}
+		return user, true
+	}
+	email := normalizeEmail(id.Email)
+	if email == "" || !id.EmailVerified {
+		RespondError(c, http.StatusForbidden, "FORBIDDEN", "The identity provider did not confirm an email address")
+		return nil, false
+	}
+
+	var user *models.User
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		existing, err := tx.Users().GetByEmail(ctx, brandID, email)
+		switch {
+		case err == nil:
+			user = existing
+		case !errors.Is(err, store.ErrNotFound):
+			return err
+		case provider.DefaultRole == "":
+			return store.ErrNotFound
+		default:
+			now := time.Now()
+			user = &models.User{BrandID: brandID, Email: email, Role: provider.DefaultRole, EmailVerifiedAt: &now}
+			if err := tx.Users().Create(ctx, user); err != nil {
+				return err
+			}
+		}
+		return tx.SSO().CreateIdentity(ctx, &models.UserIdentity{
+			BrandID: brandID, UserID: user.ID, Issuer: id.Issuer, Subject: id.Subject,
+		})
+	})
+	switch {
+	case err == nil:
+		return user, true
+	case errors.Is(err, store.ErrNotFound):
+		RespondError(c, http.StatusForbidden, "FORBIDDEN", "No user with this email exists in the brand")
+	case errors.Is(err, store.ErrConflict):
+		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "This identity is already linked; try again")
+	default:
+		c.Error(fmt.Errorf("sso user: %w", err))
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to sign in")
+	}
+	return nil, false
+}
+
+func oidcConfig(p *models.OIDCProvider) oidc.Config {
+	return oidc.Config{ClientID: p.ClientID, ClientSecret: p.ClientSecret, RedirectURL: p.RedirectURL}
+}
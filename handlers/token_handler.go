@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"APPDROP/auth"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	grantPassword     = "password"
+	grantTwoFactor    = "two_factor"
+	grantRefreshToken = "refresh_token"
+)
+
+// TokenRequest carries the fields of one grant: email and password; challenge with code or recovery_code;
+// or refresh_token.
+type TokenRequest struct {
+	GrantType    string `json:"grant_type"`
+	Email        string `json:"email"`
+	Password     string `json:"password"`
+	Challenge    string `json:"challenge"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Token is the login endpoint for clients that cannot hold cookies, such as mobile apps and CLIs.
+// It runs the same checks as POST /login, /login/2fa and /refresh but returns the tokens in the body,
+// to be sent as "Authorization: Bearer <access_token>". No cookies are set, so no CSRF token is needed.
+func (h *Handler) Token(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	var req TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	var resp gin.H
+	switch req.GrantType {
+	case grantPassword:
+		user, account, ok := h.passwordLogin(c, brandID, req.Email, req.Password)
+		if !ok {
+			return
+		}
+		tokens, err := h.startSession(c, user)
+		if err != nil {
+			RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create session")
+			return
+		}
+		h.loginSucceeded(c, account)
+		resp = tokenResponse(tokens)
+		resp["user_id"], resp["role"] = user.ID.String(), user.Role
+	case grantTwoFactor:
+		user, account, recoveryCodesRemaining, ok := h.twoFactorLogin(c, brandID, LoginTwoFactorRequest{
+			Challenge: req.Challenge, Code: req.Code, RecoveryCode: req.RecoveryCode,
+		})
+		if !ok {
+			return
+		}
+		tokens, err := h.startSession(c, user)
+		if err != nil {
+			RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create session")
+			return
+		}
+		h.loginSucceeded(c, account)
+		resp = tokenResponse(tokens)
+		resp["user_id"], resp["role"] = user.ID.String(), user.Role
+		if recoveryCodesRemaining >= 0 {
+			resp["recovery_codes_remaining"] = recoveryCodesRemaining
+		}
+	case grantRefreshToken:
+		tokens, ok := h.refreshSession(c, brandID, req.RefreshToken, false)
+		if !ok {
+			return
+		}
+		resp = tokenResponse(tokens)
+	default:
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "grant_type must be password, two_factor or refresh_token")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
+
+func tokenResponse(tokens sessionTokens) gin.H {
+	return gin.H{
+		"access_token":       tokens.access,
+		"token_type":         "Bearer",
+		"expires_in":         int(auth.AccessTokenDuration.Seconds()),
+		"refresh_token":      tokens.refresh,
+		"refresh_expires_at": tokens.refreshExpiresAt,
+		"session_id":         tokens.sessionID,
+	}
+}
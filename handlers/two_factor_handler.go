@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"APPDROP/auth"
+	"APPDROP/models"
+	"APPDROP/store"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	errTwoFactorEnabled    = errors.New("two-factor authentication already enabled")
+	errTwoFactorDisabled   = errors.New("two-factor authentication not enabled")
+	errTwoFactorNotSetUp   = errors.New("two-factor authentication not set up")
+	errInvalidTOTPCode     = errors.New("invalid authentication code")
+	errInvalidRecoveryCode = errors.New("invalid recovery code")
+)
+
+type LoginTwoFactorRequest struct {
+	Challenge    string `json:"challenge"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+// LoginTwoFactor completes a login that Login answered with a challenge, starting the session only once
+// the user supplies a current TOTP code or one of their recovery codes.
+func (h *Handler) LoginTwoFactor(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	var req LoginTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "challenge and exactly one of code or recovery_code are required")
+		return
+	}
+	user, account, recoveryCodesRemaining, ok := h.twoFactorLogin(c, brandID, req)
+	if !ok {
+		return
+	}
+	tokens, err := h.startSession(c, user)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create session")
+		return
+	}
+	setSessionCookies(c, tokens)
+	h.loginSucceeded(c, account)
+	resp := loginResponse(user, tokens.csrf)
+	if recoveryCodesRemaining >= 0 {
+		resp["recovery_codes_remaining"] = recoveryCodesRemaining
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// twoFactorLogin checks the second factor for a login challenge and returns the user to start a session for,
+// and how many recovery codes are left if one was used (-1 otherwise). On failure it has already responded.
+func (h *Handler) twoFactorLogin(c *gin.Context, brandID uuid.UUID, req LoginTwoFactorRequest) (*models.User, string, int, bool) {
+	if (req.Code == "") == (req.RecoveryCode == "") {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "challenge and exactly one of code or recovery_code are required")
+		return nil, "", 0, false
+	}
+	claims, err := auth.ParseLoginChallenge(req.Challenge)
+	if err != nil || claims.BrandID != brandID {
+		RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "Login challenge is invalid or expired; sign in again")
+		return nil, "", 0, false
+	}
+	ctx := c.Request.Context()
+	var user *models.User
+	userID, err := uuid.Parse(claims.UserID)
+	if err == nil {
+		user, err = h.Store.Users().Get(ctx, brandID, userID)
+	}
+	if err != nil || !user.TwoFactorEnabled() {
+		RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "Login challenge is invalid or expired; sign in again")
+		return nil, "", 0, false
+	}
+
+	account := loginAccount(brandID, user.Email)
+	if !h.checkLoginThrottle(c, account) {
+		return nil, "", 0, false
+	}
+
+	// The code is checked against the user as locked in the Tx, so concurrent logins can't spend it twice.
+	recoveryCodesRemaining := -1
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		locked, err := lockUser(ctx, tx, brandID, user.ID)
+		if err != nil {
+			return err
+		}
+		if !locked.TwoFactorEnabled() {
+			return errTwoFactorDisabled
+		}
+		if req.Code != "" {
+			step, ok := auth.ValidateTOTP(locked.TOTPSecret, req.Code, time.Now(), locked.TOTPLastStep)
+			if !ok {
+				return errInvalidTOTPCode
+			}
+			locked.TOTPLastStep = step
+		} else {
+			remaining, ok := auth.UseRecoveryCode(locked.RecoveryCodeHashes, req.RecoveryCode)
+			if !ok {
+				return errInvalidRecoveryCode
+			}
+			locked.RecoveryCodeHashes = remaining
+			recoveryCodesRemaining = len(remaining)
+		}
+		user = locked
+		return tx.Users().Update(ctx, locked)
+	})
+	switch {
+	case errors.Is(err, errInvalidTOTPCode):
+		h.loginFailed(c, account)
+		RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid authentication code")
+		return nil, "", 0, false
+	case errors.Is(err, errInvalidRecoveryCode):
+		h.loginFailed(c, account)
+		RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid recovery code")
+		return nil, "", 0, false
+	case errors.Is(err, errTwoFactorDisabled), errors.Is(err, store.ErrNotFound):
+		RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "Login challenge is invalid or expired; sign in again")
+		return nil, "", 0, false
+	case err != nil:
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create session")
+		return nil, "", 0, false
+	}
+	return user, account, recoveryCodesRemaining, true
+}
+
+// lockUser re-reads the user and holds it until the Tx ends, so a 2FA change works on current values
+// and saving the row can't undo a concurrent change to it.
+func lockUser(ctx context.Context, tx store.Store, brandID, id uuid.UUID) (*models.User, error) {
+	if err := tx.Users().Lock(ctx, id); err != nil {
+		return nil, err
+	}
+	return tx.Users().Get(ctx, brandID, id)
+}
+
+// SetupTwoFactor starts enrollment by generating a secret for the user's authenticator app.
+// Nothing is enforced until ConfirmTwoFactor proves the app produces valid codes.
+func (h *Handler) SetupTwoFactor(c *gin.Context) {
+	user, ok := sessionUser(c)
+	if !ok {
+		return
+	}
+	brand, ok := getBrandFromContext(c)
+	if !ok || brand == nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to set up two-factor authentication")
+		return
+	}
+	ctx := c.Request.Context()
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		locked, err := lockUser(ctx, tx, user.BrandID, user.ID)
+		if err != nil {
+			return err
+		}
+		if locked.TwoFactorEnabled() {
+			return errTwoFactorEnabled
+		}
+		locked.TOTPSecret = secret
+		return tx.Users().Update(ctx, locked)
+	})
+	if errors.Is(err, errTwoFactorEnabled) {
+		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "two-factor authentication is already enabled")
+		return
+	}
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to set up two-factor authentication")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": auth.TOTPURI(secret, strings.TrimSpace(brand.Name), user.Email),
+	})
+}
+
+// ConfirmTwoFactor enables 2FA once the user enters a code from the secret issued by SetupTwoFactor,
+// and returns recovery codes. They are shown only this once.
+func (h *Handler) ConfirmTwoFactor(c *gin.Context) {
+	user, ok := sessionUser(c)
+	if !ok {
+		return
+	}
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "code is required")
+		return
+	}
+	codes, hashes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to enable two-factor authentication")
+		return
+	}
+	ctx := c.Request.Context()
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		locked, err := lockUser(ctx, tx, user.BrandID, user.ID)
+		if err != nil {
+			return err
+		}
+		if locked.TwoFactorEnabled() {
+			return errTwoFactorEnabled
+		}
+		if locked.TOTPSecret == "" {
+			return errTwoFactorNotSetUp
+		}
+		now := time.Now()
+		step, valid := auth.ValidateTOTP(locked.TOTPSecret, req.Code, now, locked.TOTPLastStep)
+		if !valid {
+			return errInvalidTOTPCode
+		}
+		updated := *locked
+		updated.TOTPEnabledAt = &now
+		updated.TOTPLastStep = step
+		updated.RecoveryCodeHashes = hashes
+		if err := tx.Users().Update(ctx, &updated); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditUpdate, EntityType: models.AuditEntityUser, EntityID: user.ID}, locked, &updated)
+	})
+	switch {
+	case errors.Is(err, errTwoFactorEnabled):
+		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "two-factor authentication is already enabled")
+		return
+	case errors.Is(err, errTwoFactorNotSetUp):
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "start with POST /users/me/2fa/setup")
+		return
+	case errors.Is(err, errInvalidTOTPCode):
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid authentication code")
+		return
+	case err != nil:
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to enable two-factor authentication")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication enabled", "recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after re-checking their password.
+func (h *Handler) RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := h.reauthenticate(c)
+	if !ok {
+		return
+	}
+	codes, hashes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to generate recovery codes")
+		return
+	}
+	ctx := c.Request.Context()
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		locked, err := lockUser(ctx, tx, user.BrandID, user.ID)
+		if err != nil {
+			return err
+		}
+		if !locked.TwoFactorEnabled() {
+			return errTwoFactorDisabled
+		}
+		locked.RecoveryCodeHashes = hashes
+		if err := tx.Users().Update(ctx, locked); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditUpdate, EntityType: models.AuditEntityUser, EntityID: user.ID},
+			nil, gin.H{"recovery_codes_regenerated": true})
+	})
+	if errors.Is(err, errTwoFactorDisabled) {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "two-factor authentication is not enabled")
+		return
+	}
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to generate recovery codes")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTwoFactor turns 2FA off after re-checking the user's password.
+func (h *Handler) DisableTwoFactor(c *gin.Context) {
+	user, ok := h.reauthenticate(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		locked, err := lockUser(ctx, tx, user.BrandID, user.ID)
+		if err != nil {
+			return err
+		}
+		updated := *locked
+		updated.TOTPSecret = ""
+		updated.TOTPEnabledAt = nil
+		updated.TOTPLastStep = 0
+		updated.RecoveryCodeHashes = nil
+		if err := tx.Users().Update(ctx, &updated); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditUpdate, EntityType: models.AuditEntityUser, EntityID: user.ID}, locked, &updated)
+	})
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to disable two-factor authentication")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// reauthenticate requires the session user to send their password again before a sensitive change.
+func (h *Handler) reauthenticate(c *gin.Context) (*models.User, bool) {
+	user, ok := sessionUser(c)
+	if !ok {
+		return nil, false
+	}
+	var req TwoFactorPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Password == "" {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "password is required")
+		return nil, false
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		RespondError(c, http.StatusForbidden, "FORBIDDEN", "Password is incorrect")
+		return nil, false
+	}
+	return user, true
+}
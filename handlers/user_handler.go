@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"APPDROP/models"
+	"APPDROP/store"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type CreateUserRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type UpdateUserRequest struct {
+	Role     *string `json:"role"`
+	Password *string `json:"password"`
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// countOtherOwners returns how many owners the brand has besides the given user.
+func (h *Handler) countOtherOwners(ctx context.Context, user *models.User) (int64, error) {
+	count, err := h.Store.Users().CountByRole(ctx, user.BrandID, models.RoleOwner)
+	if err != nil {
+		return 0, err
+	}
+	if user.Role == models.RoleOwner {
+		count--
+	}
+	return count, nil
+}
+
+func (h *Handler) ListUsers(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	users, err := h.Store.Users().List(c.Request.Context(), brandID)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch users")
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+func (h *Handler) GetUserMe(c *gin.Context) {
+	user, ok := getCurrentUser(c)
+	if !ok {
+		RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "Missing or invalid session")
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword sets the signed-in user's password and signs them out of every other session, so
+// tokens taken before the change stop working.
+func (h *Handler) ChangePassword(c *gin.Context) {
+	user, ok := sessionUser(c)
+	if !ok {
+		return
+	}
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "current_password and new_password are required")
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		RespondError(c, http.StatusForbidden, "FORBIDDEN", "Current password is incorrect")
+		return
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to hash password")
+		return
+	}
+	updated := *user
+	updated.PasswordHash = string(hashedPassword)
+	current, _ := currentSessionID(c)
+	ctx := c.Request.Context()
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Users().Update(ctx, &updated); err != nil {
+			return err
+		}
+		if err := revokeUserSessions(ctx, tx, &updated, current); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditUpdate, EntityType: models.AuditEntityUser, EntityID: user.ID},
+			passwordChange{User: user}, passwordChange{User: &updated, PasswordChanged: true})
+	})
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update password")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "password updated"})
+}
+
+func (h *Handler) GetUserByID(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid user ID")
+		return
+	}
+	ctx := c.Request.Context()
+	user, err := h.Store.Users().Get(ctx, brandID, userID)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "User not found")
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *Handler) CreateUser(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	email := normalizeEmail(req.Email)
+	if email == "" {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "user email is required")
+		return
+	}
+	if req.Password == "" {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "user password is required")
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleViewer
+	}
+	if !models.IsValidRole(req.Role) {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "role must be one of owner, editor, viewer")
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.Store.Users().GetByEmail(ctx, brandID, email); err == nil {
+		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "user email already exists")
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to hash password")
+		return
+	}
+	user := models.User{
+		BrandID:      brandID,
+		Email:        email,
+		PasswordHash: string(hashedPassword),
+		Role:         req.Role,
+	}
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Users().Create(ctx, &user); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditCreate, EntityType: models.AuditEntityUser, EntityID: user.ID}, nil, user)
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "user email already exists")
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to create user")
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+func (h *Handler) UpdateUser(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid user ID")
+		return
+	}
+	ctx := c.Request.Context()
+	user, err := h.Store.Users().Get(ctx, brandID, userID)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "User not found")
+		return
+	}
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	before := *user
+
+	if req.Role != nil {
+		if !models.IsValidRole(*req.Role) {
+			RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "role must be one of owner, editor, viewer")
+			return
+		}
+		if user.Role == models.RoleOwner && *req.Role != models.RoleOwner {
+			others, err := h.countOtherOwners(ctx, user)
+			if err != nil {
+				RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update user")
+				return
+			}
+			if others == 0 {
+				RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "brand must keep at least one owner")
+				return
+			}
+		}
+		user.Role = *req.Role
+	}
+	if req.Password != nil {
+		if *req.Password == "" {
+			RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "user password is required")
+			return
+		}
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to hash password")
+			return
+		}
+		user.PasswordHash = string(hashedPassword)
+	}
+
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Users().Update(ctx, user); err != nil {
+			return err
+		}
+		// As after a password reset, the user signs in again, so no client keeps working on tokens from before the change.
+		if user.Role != before.Role || req.Password != nil {
+			if err := revokeUserSessions(ctx, tx, user, uuid.Nil); err != nil {
+				return err
+			}
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditUpdate, EntityType: models.AuditEntityUser, EntityID: user.ID},
+			passwordChange{User: &before}, passwordChange{User: user, PasswordChanged: req.Password != nil})
+	})
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update user")
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *Handler) DeleteUser(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid user ID")
+		return
+	}
+	ctx := c.Request.Context()
+	user, err := h.Store.Users().Get(ctx, brandID, userID)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "User not found")
+		return
+	}
+	if user.Role == models.RoleOwner {
+		others, err := h.countOtherOwners(ctx, user)
+		if err != nil {
+			RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete user")
+			return
+		}
+		if others == 0 {
+			RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "brand must keep at least one owner")
+			return
+		}
+	}
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Users().Delete(ctx, brandID, user.ID); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditDelete, EntityType: models.AuditEntityUser, EntityID: user.ID}, user, nil)
+	})
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete user")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
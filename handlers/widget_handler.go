@@ -1,15 +1,20 @@
 package handlers
 
 import (
-	"APPDROP/db"
 	"APPDROP/models"
+	"APPDROP/store"
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-func AddWidget(c *gin.Context) {
+func (h *Handler) AddWidget(c *gin.Context) {
 	brandID, ok := getBrandID(c)
 	if !ok {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
@@ -20,8 +25,8 @@ func AddWidget(c *gin.Context) {
 		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid page ID")
 		return
 	}
-	var page models.Page
-	if err := db.DB.Where("id = ? AND brand_id = ?", pageID, brandID).First(&page).Error; err != nil {
+	ctx := c.Request.Context()
+	if _, err := h.Store.Pages().Get(ctx, brandID, pageID); err != nil {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
 		return
 	}
@@ -30,18 +35,67 @@ func AddWidget(c *gin.Context) {
 		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
 		return
 	}
-	if !IsAllowedWidgetType(widget.Type) {
+	widgetType, ok := h.LookupWidgetType(ctx, brandID, widget.Type)
+	if !ok {
 		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid widget type")
 		return
 	}
+	widget.Config = withDefaultConfig(widget.Config, widgetType.DefaultConfig)
+	if !validateWidgetConfig(c, widget, widgetType.Schema) {
+		return
+	}
+	widget.ID = uuid.Nil
 	widget.PageID = pageID
-	if err := db.DB.Create(&widget).Error; err != nil {
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Widgets().Create(ctx, &widget); err != nil {
+			return err
+		}
+		if _, err := recordRevision(ctx, tx, brandID, pageID, currentUserID(c), models.RevisionWidgetAdded); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditCreate, EntityType: models.AuditEntityWidget, EntityID: widget.ID}, nil, widget)
+	})
+	if err != nil {
 		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create widget")
 		return
 	}
 	c.JSON(http.StatusCreated, widget)
 }
-func UpdateWidget(c *gin.Context) {
+
+// validateWidgetConfig responds 400 with field details and returns false when the config doesn't match schema.
+func validateWidgetConfig(c *gin.Context, widget models.Widget, schema models.ConfigSchema) bool {
+	if errs := ValidateWidgetConfig(schema, widget.Config); len(errs) > 0 {
+		RespondValidationErrors(c, "Invalid config for widget type "+widget.Type, errs)
+		return false
+	}
+	return true
+}
+
+// withDefaultConfig fills keys missing from config with the widget type's defaults.
+func withDefaultConfig(config, defaults map[string]interface{}) map[string]interface{} {
+	if len(defaults) == 0 {
+		return config
+	}
+	merged := make(map[string]interface{}, len(defaults)+len(config))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range config {
+		merged[k] = v
+	}
+	return merged
+}
+
+// UpdateWidgetRequest is the body of PUT /widgets/:id. Fields left out keep their value. A config that is
+// sent replaces the stored one as a whole, with the type's defaults filling keys it leaves out, as on create.
+type UpdateWidgetRequest struct {
+	Type     *string                `json:"type"`
+	Position *int                   `json:"position"`
+	Config   map[string]interface{} `json:"config"`
+	PageID   *uuid.UUID             `json:"page_id"`
+}
+
+func (h *Handler) UpdateWidget(c *gin.Context) {
 	brandID, ok := getBrandID(c)
 	if !ok {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
@@ -52,32 +106,62 @@ func UpdateWidget(c *gin.Context) {
 		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid Widget ID")
 		return
 	}
-	var widget models.Widget
-	if err := db.DB.First(&widget, "id = ?", widgetID).Error; err != nil {
+	ctx := c.Request.Context()
+	widget, err := h.Store.Widgets().Get(ctx, widgetID)
+	if err != nil {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Widget not found")
 		return
 	}
-	var page models.Page
-	if err := db.DB.Where("id = ? AND brand_id = ?", widget.PageID, brandID).First(&page).Error; err != nil {
+	page, err := h.Store.Pages().Get(ctx, brandID, widget.PageID)
+	if err != nil {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
 		return
 	}
-	if err := c.ShouldBindJSON(&widget); err != nil {
+	var req UpdateWidgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
 		return
 	}
+	// The body can't move the widget: POST /widgets/:id/move checks the destination belongs to the brand.
+	if req.PageID != nil && *req.PageID != widget.PageID {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "page_id cannot be changed here; use POST /widgets/:id/move")
+		return
+	}
+	before := *widget
+	if req.Type != nil {
+		widget.Type = *req.Type
+	}
+	if req.Position != nil {
+		widget.Position = *req.Position
+	}
 
-	if !IsAllowedWidgetType(widget.Type) {
+	widgetType, ok := h.LookupWidgetType(ctx, brandID, widget.Type)
+	if !ok {
 		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid widget type")
 		return
 	}
-	if err := db.DB.Save(&widget).Error; err != nil {
+	if req.Config != nil {
+		widget.Config = withDefaultConfig(req.Config, widgetType.DefaultConfig)
+	}
+	if !validateWidgetConfig(c, *widget, widgetType.Schema) {
+		return
+	}
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Widgets().Update(ctx, widget); err != nil {
+			return err
+		}
+		if _, err := recordRevision(ctx, tx, brandID, page.ID, currentUserID(c), models.RevisionWidgetUpdated); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditUpdate, EntityType: models.AuditEntityWidget, EntityID: widget.ID}, before, widget)
+	})
+	if err != nil {
 		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update the widget")
 		return
 	}
 	c.JSON(http.StatusOK, widget)
 }
-func DeleteWidget(c *gin.Context) {
+func (h *Handler) DeleteWidget(c *gin.Context) {
 	brandID, ok := getBrandID(c)
 	if !ok {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
@@ -88,17 +172,27 @@ func DeleteWidget(c *gin.Context) {
 		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid widget ID")
 		return
 	}
-	var widget models.Widget
-	if err := db.DB.First(&widget, "id = ?", widgetID).Error; err != nil {
+	ctx := c.Request.Context()
+	widget, err := h.Store.Widgets().Get(ctx, widgetID)
+	if err != nil {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Widget not found")
 		return
 	}
-	var page models.Page
-	if err := db.DB.Where("id = ? AND brand_id = ?", widget.PageID, brandID).First(&page).Error; err != nil {
+	page, err := h.Store.Pages().Get(ctx, brandID, widget.PageID)
+	if err != nil {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
 		return
 	}
-	if err := db.DB.Delete(&models.Widget{}, "id = ?", widgetID).Error; err != nil {
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Widgets().Delete(ctx, widgetID); err != nil {
+			return err
+		}
+		if _, err := recordRevision(ctx, tx, brandID, page.ID, currentUserID(c), models.RevisionWidgetDeleted); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditDelete, EntityType: models.AuditEntityWidget, EntityID: widgetID}, widget, nil)
+	})
+	if err != nil {
 		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete widget")
 		return
 	}
@@ -106,11 +200,178 @@ func DeleteWidget(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// WidgetPlacementRequest names where a copied or moved widget goes. Without a position it is appended;
+// otherwise it is inserted before the widget currently at that index, and positions past the end append.
+type WidgetPlacementRequest struct {
+	PageID   uuid.UUID `json:"page_id"`
+	Position *int      `json:"position"`
+}
+
+// findWidgetPlacement loads the brand's widget from the path and the destination page from the body,
+// responding itself when either is missing or the request is invalid.
+func (h *Handler) findWidgetPlacement(c *gin.Context) (*models.Widget, *models.Page, WidgetPlacementRequest, bool) {
+	var req WidgetPlacementRequest
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return nil, nil, req, false
+	}
+	widgetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid widget ID")
+		return nil, nil, req, false
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.PageID == uuid.Nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "page_id is required")
+		return nil, nil, req, false
+	}
+	if req.Position != nil && *req.Position < 0 {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "position must not be negative")
+		return nil, nil, req, false
+	}
+	ctx := c.Request.Context()
+	widget, err := h.Store.Widgets().Get(ctx, widgetID)
+	if err == nil {
+		_, err = h.Store.Pages().Get(ctx, brandID, widget.PageID)
+	}
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Widget not found")
+		return nil, nil, req, false
+	}
+	page, err := h.Store.Pages().Get(ctx, brandID, req.PageID)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Destination page not found")
+		return nil, nil, req, false
+	}
+	return widget, page, req, true
+}
+
+// placeWidget puts widget, already saved on pageID, at position among the page's widgets (the end when nil)
+// and renumbers the page's widgets 0..n-1 in their resulting order.
+func placeWidget(ctx context.Context, tx store.Store, pageID uuid.UUID, widget *models.Widget, position *int) error {
+	current, err := tx.Widgets().ListByPage(ctx, pageID, "")
+	if err != nil {
+		return err
+	}
+	others := slices.DeleteFunc(current, func(w models.Widget) bool { return w.ID == widget.ID })
+	index := len(others)
+	if position != nil {
+		index = min(*position, index)
+	}
+	ordered := slices.Insert(others, index, *widget)
+	for i, w := range ordered {
+		if w.ID == widget.ID || w.Position != i {
+			if err := tx.Widgets().SetPosition(ctx, w.ID, i); err != nil {
+				return err
+			}
+		}
+	}
+	widget.Position = index
+	return nil
+}
+
+// compactWidgets renumbers the page's widgets 0..n-1 in their current order, closing the gap a move leaves.
+func compactWidgets(ctx context.Context, tx store.Store, pageID uuid.UUID) error {
+	widgets, err := tx.Widgets().ListByPage(ctx, pageID, "")
+	if err != nil {
+		return err
+	}
+	for i, w := range widgets {
+		if w.Position != i {
+			if err := tx.Widgets().SetPosition(ctx, w.ID, i); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lockPages locks the pages in a fixed order, so two moves between the same pages can't deadlock.
+func lockPages(ctx context.Context, tx store.Store, ids ...uuid.UUID) error {
+	slices.SortFunc(ids, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+	for _, id := range slices.Compact(ids) {
+		if err := tx.Pages().Lock(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyWidget adds a copy of a widget to a page of the same brand, which may be the widget's own page.
+func (h *Handler) CopyWidget(c *gin.Context) {
+	source, page, req, ok := h.findWidgetPlacement(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	widget := models.Widget{PageID: page.ID, Type: source.Type, Config: source.Config}
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := lockPages(ctx, tx, page.ID); err != nil {
+			return err
+		}
+		if err := tx.Widgets().Create(ctx, &widget); err != nil {
+			return err
+		}
+		if err := placeWidget(ctx, tx, page.ID, &widget, req.Position); err != nil {
+			return err
+		}
+		if _, err := recordRevision(ctx, tx, page.BrandID, page.ID, currentUserID(c), models.RevisionWidgetAdded); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditCopy, EntityType: models.AuditEntityWidget, EntityID: widget.ID}, nil, widget)
+	})
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to copy widget")
+		return
+	}
+	c.JSON(http.StatusCreated, widget)
+}
+
+// MoveWidget moves a widget to a position on a page of the same brand; with its own page it just repositions it.
+// The source page's remaining widgets are renumbered, and both pages get a revision.
+func (h *Handler) MoveWidget(c *gin.Context) {
+	widget, page, req, ok := h.findWidgetPlacement(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	before := *widget
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := lockPages(ctx, tx, before.PageID, page.ID); err != nil {
+			return err
+		}
+		widget.PageID = page.ID
+		if err := tx.Widgets().Update(ctx, widget); err != nil {
+			return err
+		}
+		if err := placeWidget(ctx, tx, page.ID, widget, req.Position); err != nil {
+			return err
+		}
+		if before.PageID != page.ID {
+			if err := compactWidgets(ctx, tx, before.PageID); err != nil {
+				return err
+			}
+			if _, err := recordRevision(ctx, tx, page.BrandID, before.PageID, currentUserID(c), models.RevisionWidgetMoved); err != nil {
+				return err
+			}
+		}
+		if _, err := recordRevision(ctx, tx, page.BrandID, page.ID, currentUserID(c), models.RevisionWidgetMoved); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditMove, EntityType: models.AuditEntityWidget, EntityID: widget.ID}, before, widget)
+	})
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to move widget")
+		return
+	}
+	c.JSON(http.StatusOK, widget)
+}
+
 type ReorderRequest struct {
 	WidgetIDs []uuid.UUID `json:"widget_ids"`
 }
 
-func ReorderWidgets(c *gin.Context) {
+func (h *Handler) ReorderWidgets(c *gin.Context) {
 	brandID, ok := getBrandID(c)
 	if !ok {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
@@ -121,8 +382,8 @@ func ReorderWidgets(c *gin.Context) {
 		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid page ID")
 		return
 	}
-	var page models.Page
-	if err := db.DB.Where("id = ? AND brand_id = ?", pageID, brandID).First(&page).Error; err != nil {
+	ctx := c.Request.Context()
+	if _, err := h.Store.Pages().Get(ctx, brandID, pageID); err != nil {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
 		return
 	}
@@ -131,14 +392,88 @@ func ReorderWidgets(c *gin.Context) {
 		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
 		return
 	}
-	for index, widgetID := range req.WidgetIDs {
-		db.DB.Model(&models.Widget{}).Where("id = ? AND page_id = ?", widgetID, pageID).Update("Position", index)
+	var widgets []models.Widget
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Pages().Lock(ctx, pageID); err != nil {
+			return err
+		}
+		current, err := tx.Widgets().ListByPage(ctx, pageID, "")
+		if err != nil {
+			return err
+		}
+		if details := validateReorder(current, req.WidgetIDs); len(details) > 0 {
+			return &validationError{message: "widget_ids must list every widget on the page exactly once", details: details}
+		}
+		for index, widgetID := range req.WidgetIDs {
+			if err := tx.Widgets().SetPosition(ctx, widgetID, index); err != nil {
+				return err
+			}
+		}
+		if _, err := recordRevision(ctx, tx, brandID, pageID, currentUserID(c), models.RevisionWidgetsReordered); err != nil {
+			return err
+		}
+		widgets, err = tx.Widgets().ListByPage(ctx, pageID, "")
+		if err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditReorder, EntityType: models.AuditEntityPage, EntityID: pageID},
+			widgetOrder{widgetIDs(current)}, widgetOrder{req.WidgetIDs})
+	})
+	var verr *validationError
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"status": "reordered", "widgets": widgets})
+	case errors.As(err, &verr):
+		RespondValidationErrors(c, verr.message, verr.details)
+	default:
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to reorder widgets")
+	}
+}
+
+// widgetOrder is how a reorder appears in the audit log.
+type widgetOrder struct {
+	WidgetIDs []uuid.UUID `json:"widget_ids"`
+}
+
+func widgetIDs(widgets []models.Widget) []uuid.UUID {
+	ids := make([]uuid.UUID, len(widgets))
+	for i, w := range widgets {
+		ids[i] = w.ID
 	}
-	c.JSON(http.StatusOK, gin.H{"status": "reordered"})
+	return ids
+}
 
+// validateReorder checks that ids is a permutation of the page's current widgets.
+func validateReorder(current []models.Widget, ids []uuid.UUID) []FieldError {
+	onPage := make(map[uuid.UUID]bool, len(current))
+	for _, w := range current {
+		onPage[w.ID] = true
+	}
+	var details []FieldError
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for i, id := range ids {
+		field := fmt.Sprintf("widget_ids[%d]", i)
+		switch {
+		case seen[id]:
+			details = append(details, FieldError{Field: field, Message: "duplicate widget ID " + id.String()})
+		case !onPage[id]:
+			details = append(details, FieldError{Field: field, Message: "widget " + id.String() + " does not belong to this page"})
+		}
+		seen[id] = true
+	}
+	var missing []string
+	for _, w := range current {
+		if !seen[w.ID] {
+			missing = append(missing, w.ID.String())
+		}
+	}
+	if len(missing) > 0 {
+		details = append(details, FieldError{Field: "widget_ids", Message: "missing widgets: " + strings.Join(missing, ", ")})
+	}
+	return details
 }
 
-func DeletePage(c *gin.Context) {
+func (h *Handler) DeletePage(c *gin.Context) {
 	brandID, ok := getBrandID(c)
 	if !ok {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
@@ -149,8 +484,9 @@ func DeletePage(c *gin.Context) {
 		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid page ID")
 		return
 	}
-	var page models.Page
-	if err := db.DB.Where("brand_id = ?", brandID).First(&page, "id = ?", pageID).Error; err != nil {
+	ctx := c.Request.Context()
+	page, err := h.Store.Pages().Get(ctx, brandID, pageID)
+	if err != nil {
 		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
 		return
 	}
@@ -160,7 +496,13 @@ func DeletePage(c *gin.Context) {
 		return
 	}
 
-	if err := db.DB.Delete(&page).Error; err != nil {
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.Pages().Delete(ctx, brandID, page.ID); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditDelete, EntityType: models.AuditEntityPage, EntityID: page.ID}, page, nil)
+	})
+	if err != nil {
 		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete page")
 		return
 	}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"APPDROP/models"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidateWidgetConfig checks config against schema and returns one FieldError per offending key, sorted by field.
+func ValidateWidgetConfig(schema models.ConfigSchema, config map[string]interface{}) []FieldError {
+	var errs []FieldError
+	for name, field := range schema.Fields {
+		value, present := config[name]
+		if !present || value == nil {
+			if field.Required {
+				errs = append(errs, FieldError{Field: name, Message: "is required"})
+			}
+			continue
+		}
+		if msg := validateField(field, value); msg != "" {
+			errs = append(errs, FieldError{Field: name, Message: msg})
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+func validateField(field models.FieldSchema, value interface{}) string {
+	if !matchesType(field.Type, value) {
+		return "must be " + article(field.Type) + " " + field.Type
+	}
+	switch v := value.(type) {
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if field.Min != nil && length < *field.Min {
+			return fmt.Sprintf("must be at least %g characters", *field.Min)
+		}
+		if field.Max != nil && length > *field.Max {
+			return fmt.Sprintf("must be at most %g characters", *field.Max)
+		}
+		if len(field.Enum) > 0 && !containsString(field.Enum, v) {
+			return "must be one of " + strings.Join(field.Enum, ", ")
+		}
+		if field.Format == models.FormatURL {
+			u, err := url.Parse(v)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return "must be an http(s) URL"
+			}
+		}
+	case float64:
+		if field.Min != nil && v < *field.Min {
+			return fmt.Sprintf("must be at least %g", *field.Min)
+		}
+		if field.Max != nil && v > *field.Max {
+			return fmt.Sprintf("must be at most %g", *field.Max)
+		}
+	case []interface{}:
+		length := float64(len(v))
+		if field.Min != nil && length < *field.Min {
+			return fmt.Sprintf("must have at least %g items", *field.Min)
+		}
+		if field.Max != nil && length > *field.Max {
+			return fmt.Sprintf("must have at most %g items", *field.Max)
+		}
+		if field.Items != "" {
+			for i, item := range v {
+				if !matchesType(field.Items, item) {
+					return fmt.Sprintf("item %d must be %s %s", i, article(field.Items), field.Items)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// matchesType reports whether a JSON-decoded value has the given schema type.
+func matchesType(fieldType string, value interface{}) bool {
+	switch fieldType {
+	case models.FieldString:
+		_, ok := value.(string)
+		return ok
+	case models.FieldNumber:
+		_, ok := value.(float64)
+		return ok
+	case models.FieldInteger:
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case models.FieldBoolean:
+		_, ok := value.(bool)
+		return ok
+	case models.FieldArray:
+		_, ok := value.([]interface{})
+		return ok
+	case models.FieldObject:
+		_, ok := value.(map[string]interface{})
+		return ok
+	}
+	return false
+}
+
+func article(word string) string {
+	if strings.ContainsAny(word[:1], "aeiou") {
+		return "an"
+	}
+	return "a"
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"APPDROP/models"
+	"encoding/json"
+	"testing"
+)
+
+func TestValidateWidgetConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		widgetType string
+		config     string
+		wantFields []string
+	}{
+		{"valid banner", "banner", `{"image_url": "https://cdn.example.com/a.png", "title": "Hi"}`, nil},
+		{"banner without image", "banner", `{"title": "Hi"}`, []string{"image_url"}},
+		{"banner with relative image", "banner", `{"image_url": "/a.png"}`, []string{"image_url"}},
+		{"grid with string columns", "product_grid", `{"columns": "3"}`, []string{"columns"}},
+		{"grid with fractional columns", "product_grid", `{"columns": 2.5}`, []string{"columns"}},
+		{"grid with too many columns", "product_grid", `{"columns": 12}`, []string{"columns"}},
+		{"grid with non-string product", "product_grid", `{"columns": 2, "product_ids": ["a", 7]}`, []string{"product_ids"}},
+		{"text with bad align", "text", `{"content": "x", "align": "justify"}`, []string{"align"}},
+		{"empty spacer", "spacer", `{}`, []string{"height"}},
+		{"unknown keys allowed", "spacer", `{"height": 20, "color": "red"}`, nil},
+		{"multiple errors sorted", "image", `{"url": 1, "alt": false}`, []string{"alt", "url"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.config), &config); err != nil {
+				t.Fatalf("bad test config: %v", err)
+			}
+			schema, ok := BuiltinWidgetSchemas[tt.widgetType]
+			if !ok {
+				t.Fatalf("no schema for %q", tt.widgetType)
+			}
+			errs := ValidateWidgetConfig(schema, config)
+			if len(errs) != len(tt.wantFields) {
+				t.Fatalf("got errors %+v, want fields %v", errs, tt.wantFields)
+			}
+			for i, field := range tt.wantFields {
+				if errs[i].Field != field {
+					t.Errorf("error %d: got field %q, want %q", i, errs[i].Field, field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateWidgetTypeDefinition(t *testing.T) {
+	var schema models.ConfigSchema
+	if err := json.Unmarshal([]byte(`{"fields": {
+		"src": {"type": "string", "required": true, "format": "url"},
+		"seconds": {"type": "integer", "min": 10, "max": 1},
+		"tags": {"type": "string", "items": "string"},
+		"mode": {"type": "video"}
+	}}`), &schema); err != nil {
+		t.Fatalf("bad test schema: %v", err)
+	}
+	errs := validateWidgetTypeDefinition(schema, map[string]interface{}{"src": 42})
+	want := []string{"default_config.src", "schema.fields.mode.type", "schema.fields.seconds.min", "schema.fields.tags.items"}
+	if len(errs) != len(want) {
+		t.Fatalf("got errors %+v, want fields %v", errs, want)
+	}
+	for i, field := range want {
+		if errs[i].Field != field {
+			t.Errorf("error %d: got field %q, want %q", i, errs[i].Field, field)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"APPDROP/models"
+	"APPDROP/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var widgetTypeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,39}$`)
+
+type WidgetTypeRequest struct {
+	Name          string                 `json:"name"`
+	DisplayName   *string                `json:"display_name"`
+	Description   *string                `json:"description"`
+	Icon          *string                `json:"icon"`
+	Schema        *models.ConfigSchema   `json:"schema"`
+	DefaultConfig map[string]interface{} `json:"default_config"`
+}
+
+// validateWidgetTypeDefinition checks that a schema only uses known field types and that defaults fit it.
+func validateWidgetTypeDefinition(schema models.ConfigSchema, defaults map[string]interface{}) []FieldError {
+	var errs []FieldError
+	for name, field := range schema.Fields {
+		path := "schema.fields." + name
+		if !isKnownFieldType(field.Type) {
+			errs = append(errs, FieldError{Field: path + ".type", Message: "unknown field type " + fmt.Sprintf("%q", field.Type)})
+			continue
+		}
+		if field.Items != "" && (field.Type != models.FieldArray || !isKnownFieldType(field.Items)) {
+			errs = append(errs, FieldError{Field: path + ".items", Message: "only arrays may declare items, of a known field type"})
+		}
+		if len(field.Enum) > 0 && field.Type != models.FieldString {
+			errs = append(errs, FieldError{Field: path + ".enum", Message: "only string fields may declare enum"})
+		}
+		if field.Format != "" && (field.Format != models.FormatURL || field.Type != models.FieldString) {
+			errs = append(errs, FieldError{Field: path + ".format", Message: "only string fields may declare format, which must be url"})
+		}
+		if field.Min != nil && field.Max != nil && *field.Min > *field.Max {
+			errs = append(errs, FieldError{Field: path + ".min", Message: "must not exceed max"})
+		}
+	}
+	for name, value := range defaults {
+		field, ok := schema.Fields[name]
+		if !ok || !isKnownFieldType(field.Type) {
+			continue
+		}
+		if msg := validateField(field, value); msg != "" {
+			errs = append(errs, FieldError{Field: "default_config." + name, Message: msg})
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+func isKnownFieldType(t string) bool {
+	switch t {
+	case models.FieldString, models.FieldNumber, models.FieldInteger, models.FieldBoolean, models.FieldArray, models.FieldObject:
+		return true
+	}
+	return false
+}
+
+func (h *Handler) findBrandWidgetType(c *gin.Context) (*models.WidgetType, bool) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return nil, false
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid widget type ID")
+		return nil, false
+	}
+	widgetType, err := h.Store.WidgetTypes().Get(c.Request.Context(), brandID, id)
+	if err != nil {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Widget type not found")
+		return nil, false
+	}
+	return widgetType, true
+}
+
+func (h *Handler) ListWidgetTypes(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	custom, err := h.Store.WidgetTypes().List(c.Request.Context(), brandID)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch widget types")
+		return
+	}
+	c.JSON(http.StatusOK, append(builtinWidgetTypes(), custom...))
+}
+
+func (h *Handler) GetWidgetType(c *gin.Context) {
+	widgetType, ok := h.findBrandWidgetType(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, widgetType)
+}
+
+func (h *Handler) CreateWidgetType(c *gin.Context) {
+	brandID, ok := getBrandID(c)
+	if !ok {
+		RespondError(c, http.StatusNotFound, "NOT_FOUND", "Brand not found for this domain")
+		return
+	}
+	var req WidgetTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if !widgetTypeNamePattern.MatchString(req.Name) {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "widget type name must be 2-40 lowercase letters, digits or underscores, starting with a letter")
+		return
+	}
+	if AllowedWidgetTypes[req.Name] {
+		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "widget type name is reserved by a built-in type")
+		return
+	}
+	if req.Schema == nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "widget type schema is required")
+		return
+	}
+	if errs := validateWidgetTypeDefinition(*req.Schema, req.DefaultConfig); len(errs) > 0 {
+		RespondValidationErrors(c, "Invalid widget type definition", errs)
+		return
+	}
+	ctx := c.Request.Context()
+	if _, err := h.Store.WidgetTypes().GetByName(ctx, brandID, req.Name); err == nil {
+		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "widget type already exists")
+		return
+	}
+
+	widgetType := models.WidgetType{
+		BrandID:       brandID,
+		Name:          req.Name,
+		DisplayName:   req.Name,
+		Schema:        *req.Schema,
+		DefaultConfig: req.DefaultConfig,
+	}
+	if req.DisplayName != nil {
+		widgetType.DisplayName = *req.DisplayName
+	}
+	if req.Description != nil {
+		widgetType.Description = *req.Description
+	}
+	if req.Icon != nil {
+		widgetType.Icon = *req.Icon
+	}
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.WidgetTypes().Create(ctx, &widgetType); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditCreate, EntityType: models.AuditEntityWidgetType, EntityID: widgetType.ID}, nil, widgetType)
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "widget type already exists")
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to create widget type")
+		return
+	}
+	c.JSON(http.StatusCreated, widgetType)
+}
+
+func (h *Handler) UpdateWidgetType(c *gin.Context) {
+	widgetType, ok := h.findBrandWidgetType(c)
+	if !ok {
+		return
+	}
+	var req WidgetTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if req.Name != "" && req.Name != widgetType.Name {
+		RespondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "widget type name cannot be changed")
+		return
+	}
+	before := *widgetType
+	if req.DisplayName != nil {
+		widgetType.DisplayName = *req.DisplayName
+	}
+	if req.Description != nil {
+		widgetType.Description = *req.Description
+	}
+	if req.Icon != nil {
+		widgetType.Icon = *req.Icon
+	}
+	if req.Schema != nil {
+		widgetType.Schema = *req.Schema
+	}
+	if req.DefaultConfig != nil {
+		widgetType.DefaultConfig = req.DefaultConfig
+	}
+	if errs := validateWidgetTypeDefinition(widgetType.Schema, widgetType.DefaultConfig); len(errs) > 0 {
+		RespondValidationErrors(c, "Invalid widget type definition", errs)
+		return
+	}
+	ctx := c.Request.Context()
+	err := h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.WidgetTypes().Update(ctx, widgetType); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditUpdate, EntityType: models.AuditEntityWidgetType, EntityID: widgetType.ID}, before, widgetType)
+	})
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update widget type")
+		return
+	}
+	c.JSON(http.StatusOK, widgetType)
+}
+
+func (h *Handler) DeleteWidgetType(c *gin.Context) {
+	widgetType, ok := h.findBrandWidgetType(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	inUse, err := h.Store.Widgets().CountByType(ctx, widgetType.BrandID, widgetType.Name)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete widget type")
+		return
+	}
+	if inUse > 0 {
+		RespondError(c, http.StatusConflict, "VALIDATION_ERROR", "widget type is still used by widgets")
+		return
+	}
+	err = h.Store.Tx(ctx, func(tx store.Store) error {
+		if err := tx.WidgetTypes().Delete(ctx, widgetType.BrandID, widgetType.ID); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditEvent{Action: models.AuditDelete, EntityType: models.AuditEntityWidgetType, EntityID: widgetType.ID}, widgetType, nil)
+	})
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete widget type")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,30 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileWritesMessage(t *testing.T) {
+	dir := t.TempDir()
+	m := &File{Dir: dir, From: "noreply@appdrop.test"}
+	if err := m.Send(context.Background(), Message{To: "a@b.com", Subject: "Hi", Body: "line one\nline two"}); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+	raw, _ := os.ReadFile(files[0])
+	for _, want := range []string{"To: a@b.com\r\n", "Subject: Hi\r\n", "line one\r\nline two"} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("message missing %q:\n%s", want, raw)
+		}
+	}
+	if err := m.Send(context.Background(), Message{To: "a@b.com\r\nBcc: x@y.com", Subject: "Hi"}); err == nil {
+		t.Error("header injection in recipient was accepted")
+	}
+}
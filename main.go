@@ -2,22 +2,129 @@ package main
 
 import (
 	"APPDROP/db"
+	"APPDROP/handlers"
+	"APPDROP/mailer"
 	"APPDROP/middlewares"
 	"APPDROP/routes"
+	"APPDROP/store"
+	"APPDROP/throttle"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/joho/godotenv"
 )
 
 func main() {
-	db.Connect()
+	if err := godotenv.Load(); err != nil {
+		log.Println(" No .env file found, using system environment variables")
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "keygen" {
+		runKeygen(os.Args[2:])
+		return
+	}
 
 	r := gin.Default()
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	r.Use(middlewares.RequestLogger())
 
-	routes.RegisterRoutes(r)
+	h := handlers.New(newStore())
+	loadSigningKeys()
+	h.BrandCache = newBrandCache()
+	h.Mailer = newMailer()
+	h.AppURL = os.Getenv("APP_URL")
+	if os.Getenv("LOGIN_THROTTLE_STORE") == "postgres" {
+		if db.DB == nil {
+			log.Fatal("LOGIN_THROTTLE_STORE=postgres needs the PostgreSQL store")
+		}
+		h.LoginLimiter = throttle.NewLimiter(throttle.NewPostgres(db.DB))
+	}
+	routes.RegisterRoutes(r, h)
 
 	log.Println("Server running on port 8090")
 	r.Run(":8090")
 }
+
+// newStore picks the storage backend: PostgreSQL by default, or an in-memory store when
+// APPDROP_STORE=memory (handy for demos; nothing survives a restart).
+func newStore() store.Store {
+	if os.Getenv("APPDROP_STORE") == "memory" {
+		log.Println("Using in-memory store; data will not be persisted")
+		return store.NewMemory()
+	}
+	db.Connect()
+	return store.NewPostgres(db.DB)
+}
+
+// trustedProxies reads TRUSTED_PROXIES, a comma-separated list of proxy IPs or CIDRs whose X-Forwarded-For
+// header is believed. It defaults to none, so a client can't choose the IP that login throttling, sessions
+// and the audit log see.
+func trustedProxies() []string {
+	var proxies []string
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	return proxies
+}
+
+// newBrandCache sizes the brand resolution cache from BRAND_CACHE_SIZE (default 1000, 0 disables)
+// and BRAND_CACHE_TTL (default 60s).
+func newBrandCache() *middlewares.BrandCache {
+	size := 1000
+	if v := os.Getenv("BRAND_CACHE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("invalid BRAND_CACHE_SIZE %q", v)
+		}
+		size = n
+	}
+	if size == 0 {
+		return nil
+	}
+	ttl := time.Minute
+	if v := os.Getenv("BRAND_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatalf("invalid BRAND_CACHE_TTL %q", v)
+		}
+		ttl = d
+	}
+	return middlewares.NewBrandCache(size, ttl)
+}
+
+// newMailer sends through SMTP_HOST when it is set, and otherwise writes .eml files to MAIL_DIR (default ./mail).
+func newMailer() mailer.Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@appdrop.local"
+	}
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := 587
+		if v := os.Getenv("SMTP_PORT"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				log.Fatalf("invalid SMTP_PORT %q", v)
+			}
+			port = n
+		}
+		return &mailer.SMTP{Host: host, Port: port, Username: os.Getenv("SMTP_USERNAME"), Password: os.Getenv("SMTP_PASSWORD"), From: from}
+	}
+	dir := os.Getenv("MAIL_DIR")
+	if dir == "" {
+		dir = "mail"
+	}
+	log.Printf("SMTP_HOST not set; writing outgoing mail to %s/", dir)
+	return &mailer.File{Dir: dir, From: from}
+}
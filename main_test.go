@@ -1,64 +1,184 @@
 package main
 
 import (
+	"APPDROP/auth"
 	"APPDROP/db"
+	"APPDROP/dnsverify"
+	"APPDROP/handlers"
+	"APPDROP/mailer"
+	"APPDROP/middlewares"
+	"APPDROP/models"
+	"APPDROP/oidc/oidctest"
 	"APPDROP/routes"
+	"APPDROP/store"
+	"APPDROP/throttle"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 )
 
+// testRouter wires the API to a fresh in-memory store, or to Postgres when TEST_DATABASE_URL is set.
 func testRouter() *gin.Engine {
+	r, _ := testRouterWithHandler()
+	return r
+}
+
+// testRouterWithHandler also returns the Handler so tests can swap dependencies such as the DNS resolver.
+func testRouterWithHandler() (*gin.Engine, *handlers.Handler) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
-	routes.RegisterRoutes(r)
-	return r
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		panic(err)
+	}
+	var s store.Store = store.NewMemory()
+	if db.DB != nil {
+		s = store.NewPostgres(db.DB)
+	}
+	h := handlers.New(s)
+	h.Resolver = dnsverify.StaticResolver{}
+	h.BrandCache = middlewares.NewBrandCache(100, time.Minute)
+	h.Mailer = &mailer.Memory{}
+	routes.RegisterRoutes(r, h)
+	return r, h
 }
 
 func testBrandAndCookie(t *testing.T, r *gin.Engine) (domain, cookie string) {
 	t.Helper()
-	if db.DB == nil {
-		t.Skip("DATABASE_URL not set, skipping test that requires brand and auth")
-	}
 	domain = "testbrand"
 
 	body := `{"name":"Test Brand","domain":"testbrand","email":"test@testbrand.com","password":"secret","office_address":"","logo":""}`
-	req := httptest.NewRequest(http.MethodPost, "/brands", bytes.NewBufferString(body))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-	r.ServeHTTP(w, req)
+	w := doRequest(t, r, http.MethodPost, "/brands", body)
 	if w.Code != http.StatusCreated && w.Code != http.StatusConflict {
 		t.Fatalf("create brand: got status %d", w.Code)
 	}
 
-	loginBody := `{"email":"test@testbrand.com","password":"secret"}`
-	loginReq := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBufferString(loginBody))
-	loginReq.Header.Set("Content-Type", "application/json")
-	loginReq.Header.Set("X-Brand-Domain", domain)
-	loginW := httptest.NewRecorder()
-	r.ServeHTTP(loginW, loginReq)
+	return domain, testLogin(t, r, domain, "test@testbrand.com", "secret")
+}
+
+func testLogin(t *testing.T, r *gin.Engine, domain, email, password string) (cookie string) {
+	t.Helper()
+	loginBody := fmt.Sprintf(`{"email":%q,"password":%q}`, email, password)
+	loginW := doRequest(t, r, http.MethodPost, "/login", loginBody, withBrand(domain))
 	if loginW.Code != http.StatusOK {
 		t.Fatalf("login: got status %d, body %s", loginW.Code, loginW.Body.String())
 	}
-	setCookie := loginW.Header().Get("Set-Cookie")
-	if setCookie == "" {
+	cookie = cookieHeader(loginW)
+	if cookie == "" {
 		t.Fatal("login did not return Set-Cookie")
 	}
-	if idx := strings.Index(setCookie, ";"); idx != -1 {
-		cookie = strings.TrimSpace(setCookie[:idx])
-	} else {
-		cookie = setCookie
+	return cookie
+}
+
+// cookieHeader turns a response's Set-Cookie headers into a Cookie request header value.
+func cookieHeader(w *httptest.ResponseRecorder) string {
+	var pairs []string
+	for _, c := range w.Result().Cookies() {
+		pairs = append(pairs, c.Name+"="+c.Value)
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// setSession sends the cookies from cookieHeader, echoing the CSRF cookie in the CSRF header like the admin app does.
+func setSession(req *http.Request, cookie string) {
+	req.Header.Set("Cookie", cookie)
+	for _, pair := range strings.Split(cookie, "; ") {
+		if token, ok := strings.CutPrefix(pair, auth.CSRFCookieName()+"="); ok {
+			req.Header.Set(auth.CSRFHeader, token)
+		}
+	}
+}
+
+// requestOption adjusts a request built by doRequest. Options apply in order, so a later one overrides an earlier one.
+type requestOption func(*http.Request)
+
+// withBrand selects the brand through X-Brand-Domain.
+func withBrand(domain string) requestOption {
+	return func(req *http.Request) { req.Header.Set("X-Brand-Domain", domain) }
+}
+
+// withSession signs the request in like the admin app, with setSession. An empty cookie sends no session at all.
+func withSession(cookie string) requestOption {
+	return func(req *http.Request) {
+		req.Header.Del("Cookie")
+		req.Header.Del(auth.CSRFHeader)
+		if cookie != "" {
+			setSession(req, cookie)
+		}
+	}
+}
+
+// withCookie sends cookies without the matching CSRF header, as a cross-site request would.
+func withCookie(cookie string) requestOption {
+	return func(req *http.Request) {
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+	}
+}
+
+// withCSRF sets the CSRF header to token, if any.
+func withCSRF(token string) requestOption {
+	return func(req *http.Request) {
+		if token != "" {
+			req.Header.Set(auth.CSRFHeader, token)
+		}
+	}
+}
+
+// withAuthorization sets the Authorization header to value, if any.
+func withAuthorization(value string) requestOption {
+	return func(req *http.Request) {
+		if value != "" {
+			req.Header.Set("Authorization", value)
+		}
+	}
+}
+
+// withBearer authenticates with an API key or access token.
+func withBearer(token string) requestOption {
+	return withAuthorization("Bearer " + token)
+}
+
+func withHeader(name, value string) requestOption {
+	return func(req *http.Request) { req.Header.Set(name, value) }
+}
+
+// doRequest sends a JSON request through r and returns the recorded response.
+func doRequest(t *testing.T, r *gin.Engine, method, path, body string, opts ...requestOption) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	for _, opt := range opts {
+		opt(req)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// brandClient returns a doRequest bound to one brand and, unless cookie is empty, signed in with cookie.
+// Per-request options come last, so they can switch the brand or the credentials.
+func brandClient(t *testing.T, r *gin.Engine, domain, cookie string) func(method, path, body string, opts ...requestOption) *httptest.ResponseRecorder {
+	return func(method, path, body string, opts ...requestOption) *httptest.ResponseRecorder {
+		t.Helper()
+		return doRequest(t, r, method, path, body, append([]requestOption{withBrand(domain), withSession(cookie)}, opts...)...)
 	}
-	return domain, cookie
 }
 
 func TestHealth(t *testing.T) {
@@ -84,7 +204,7 @@ func TestGetPageByID_InvalidUUID(t *testing.T) {
 	domain, cookie := testBrandAndCookie(t, r)
 	req := httptest.NewRequest(http.MethodGet, "/pages/not-a-uuid", nil)
 	req.Header.Set("X-Brand-Domain", domain)
-	req.Header.Set("Cookie", cookie)
+	setSession(req, cookie)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
@@ -111,7 +231,7 @@ func TestCreatePage_ValidationErrors(t *testing.T) {
 			req := httptest.NewRequest(http.MethodPost, "/pages", bytes.NewBufferString(tt.body))
 			req.Header.Set("Content-Type", "application/json")
 			req.Header.Set("X-Brand-Domain", domain)
-			req.Header.Set("Cookie", cookie)
+			setSession(req, cookie)
 			w := httptest.NewRecorder()
 			r.ServeHTTP(w, req)
 			if w.Code != tt.want {
@@ -128,7 +248,7 @@ func TestAddWidget_InvalidPageID(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/pages/not-a-uuid/widgets", bytes.NewBufferString(body))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Brand-Domain", domain)
-	req.Header.Set("Cookie", cookie)
+	setSession(req, cookie)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
@@ -146,7 +266,7 @@ func TestAddWidget_InvalidType(t *testing.T) {
 	createReq := httptest.NewRequest(http.MethodPost, "/pages", bytes.NewBufferString(pageBody))
 	createReq.Header.Set("Content-Type", "application/json")
 	createReq.Header.Set("X-Brand-Domain", domain)
-	createReq.Header.Set("Cookie", cookie)
+	setSession(createReq, cookie)
 	createW := httptest.NewRecorder()
 	r.ServeHTTP(createW, createReq)
 	if createW.Code != http.StatusCreated {
@@ -163,7 +283,7 @@ func TestAddWidget_InvalidType(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/pages/"+page.ID+"/widgets", bytes.NewBufferString(widgetBody))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Brand-Domain", domain)
-	req.Header.Set("Cookie", cookie)
+	setSession(req, cookie)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
@@ -177,7 +297,7 @@ func TestGetPages_RequiresDB(t *testing.T) {
 	domain, cookie := testBrandAndCookie(t, r)
 	req := httptest.NewRequest(http.MethodGet, "/pages", nil)
 	req.Header.Set("X-Brand-Domain", domain)
-	req.Header.Set("Cookie", cookie)
+	setSession(req, cookie)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
@@ -191,9 +311,1471 @@ func TestGetPages_RequiresDB(t *testing.T) {
 	}
 }
 
-func TestMain(m *testing.M) {
-	_ = godotenv.Load()
-	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+func TestCreateUser_ValidationErrors(t *testing.T) {
+	r := testRouter()
+	domain, cookie := testBrandAndCookie(t, r)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"missing email", `{"password": "secret", "role": "editor"}`, http.StatusBadRequest},
+		{"missing password", `{"email": "new@testbrand.com", "role": "editor"}`, http.StatusBadRequest},
+		{"invalid role", `{"email": "new@testbrand.com", "password": "secret", "role": "admin"}`, http.StatusBadRequest},
+		{"duplicate owner email", `{"email": "test@testbrand.com", "password": "secret"}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Brand-Domain", domain)
+			setSession(req, cookie)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("POST /users: got status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestViewerCannotWritePages(t *testing.T) {
+	r := testRouter()
+	domain, cookie := testBrandAndCookie(t, r)
+
+	email := fmt.Sprintf("viewer-%d@testbrand.com", time.Now().UnixNano())
+	userBody := fmt.Sprintf(`{"email":%q,"password":"viewer","role":"viewer"}`, email)
+	createReq := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(userBody))
+	createReq.Header.Set("Content-Type", "application/json")
+	createReq.Header.Set("X-Brand-Domain", domain)
+	setSession(createReq, cookie)
+	createW := httptest.NewRecorder()
+	r.ServeHTTP(createW, createReq)
+	if createW.Code != http.StatusCreated {
+		t.Fatalf("create viewer: got status %d, body %s", createW.Code, createW.Body.String())
+	}
+	viewerCookie := testLogin(t, r, domain, email, "viewer")
+
+	listReq := httptest.NewRequest(http.MethodGet, "/pages", nil)
+	listReq.Header.Set("X-Brand-Domain", domain)
+	setSession(listReq, viewerCookie)
+	listW := httptest.NewRecorder()
+	r.ServeHTTP(listW, listReq)
+	if listW.Code != http.StatusOK {
+		t.Errorf("viewer GET /pages: got status %d, want %d", listW.Code, http.StatusOK)
+	}
+
+	pageReq := httptest.NewRequest(http.MethodPost, "/pages", bytes.NewBufferString(`{"name":"Nope","route":"/nope"}`))
+	pageReq.Header.Set("Content-Type", "application/json")
+	pageReq.Header.Set("X-Brand-Domain", domain)
+	setSession(pageReq, viewerCookie)
+	pageW := httptest.NewRecorder()
+	r.ServeHTTP(pageW, pageReq)
+	if pageW.Code != http.StatusForbidden {
+		t.Fatalf("viewer POST /pages: got status %d, want %d", pageW.Code, http.StatusForbidden)
+	}
+	var resp struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(pageW.Body.Bytes(), &resp); err != nil || resp.Error.Code != "FORBIDDEN" {
+		t.Errorf("viewer POST /pages: got body %s, want FORBIDDEN error", pageW.Body.String())
+	}
+}
+
+func TestPublishPage_SnapshotIgnoresLaterDrafts(t *testing.T) {
+	r := testRouter()
+	domain, cookie := testBrandAndCookie(t, r)
+	do := brandClient(t, r, domain, cookie)
+
+	route := fmt.Sprintf("/publish-%d", time.Now().UnixNano())
+	createW := do(http.MethodPost, "/pages", fmt.Sprintf(`{"name":"Live","route":%q}`, route))
+	if createW.Code != http.StatusCreated {
+		t.Fatalf("create page: got status %d", createW.Code)
+	}
+	var page struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(createW.Body.Bytes(), &page); err != nil {
+		t.Fatalf("parse page: %v", err)
+	}
+	if page.Status != "draft" {
+		t.Errorf("new page status: got %q, want %q", page.Status, "draft")
+	}
+
+	if w := do(http.MethodGet, "/pages/"+page.ID+"/published", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET published before publish: got status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := do(http.MethodPost, "/pages/"+page.ID+"/publish", ""); w.Code != http.StatusOK {
+		t.Fatalf("publish: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, "/pages/"+page.ID, `{"name":"Draft edit"}`); w.Code != http.StatusOK {
+		t.Fatalf("update page: got status %d", w.Code)
+	}
+
+	w := do(http.MethodGet, "/pages/"+page.ID+"/published", "")
+	var published struct {
+		Snapshot struct {
+			Name string `json:"name"`
+		} `json:"snapshot"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &published); err != nil {
+		t.Fatalf("parse published: %v", err)
+	}
+	if published.Snapshot.Name != "Live" {
+		t.Errorf("published name: got %q, want %q", published.Snapshot.Name, "Live")
+	}
+
+	if w := do(http.MethodPost, "/pages/"+page.ID+"/unpublish", ""); w.Code != http.StatusOK {
+		t.Fatalf("unpublish: got status %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/pages/"+page.ID+"/published", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET published after unpublish: got status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestRestorePageRevision(t *testing.T) {
+	r := testRouter()
+	domain, cookie := testBrandAndCookie(t, r)
+	do := brandClient(t, r, domain, cookie)
+
+	route := fmt.Sprintf("/revisions-%d", time.Now().UnixNano())
+	createW := do(http.MethodPost, "/pages", fmt.Sprintf(`{"name":"Original","route":%q}`, route))
+	if createW.Code != http.StatusCreated {
+		t.Fatalf("create page: got status %d", createW.Code)
+	}
+	var page struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(createW.Body.Bytes(), &page); err != nil {
+		t.Fatalf("parse page: %v", err)
+	}
+	if w := do(http.MethodPut, "/pages/"+page.ID, `{"name":"Changed"}`); w.Code != http.StatusOK {
+		t.Fatalf("update page: got status %d", w.Code)
+	}
+
+	listW := do(http.MethodGet, "/pages/"+page.ID+"/revisions", "")
+	var revisions []struct {
+		Number int    `json:"number"`
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal(listW.Body.Bytes(), &revisions); err != nil {
+		t.Fatalf("parse revisions: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Action != "page.updated" || revisions[1].Action != "page.created" {
+		t.Fatalf("revisions: got %+v, want page.updated then page.created", revisions)
+	}
+
+	if w := do(http.MethodPost, "/pages/"+page.ID+"/revisions/1/restore", ""); w.Code != http.StatusOK {
+		t.Fatalf("restore: got status %d, body %s", w.Code, w.Body.String())
+	}
+	getW := do(http.MethodGet, "/pages/"+page.ID, "")
+	var restored struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(getW.Body.Bytes(), &restored); err != nil {
+		t.Fatalf("parse page: %v", err)
+	}
+	if restored.Name != "Original" {
+		t.Errorf("restored name: got %q, want %q", restored.Name, "Original")
+	}
+}
+
+func TestPublicApp_RequiresBrandOnly(t *testing.T) {
+	r := testRouter()
+	domain, _ := testBrandAndCookie(t, r)
+
+	req := httptest.NewRequest(http.MethodGet, "/public/app", nil)
+	req.Header.Set("X-Brand-Domain", domain)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /public/app: got status %d, want %d", w.Code, http.StatusOK)
+	}
+	var manifest struct {
+		Brand struct {
+			Domain string `json:"domain"`
+		} `json:"brand"`
+		Pages []interface{} `json:"pages"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &manifest); err != nil {
+		t.Fatalf("parse manifest: %v", err)
+	}
+	if manifest.Brand.Domain != domain {
+		t.Errorf("manifest brand domain: got %q, want %q", manifest.Brand.Domain, domain)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/public/pages/does-not-exist", nil)
+	req.Header.Set("X-Brand-Domain", domain)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /public/pages/missing: got status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestCustomWidgetType_DefaultsAndValidation(t *testing.T) {
+	r := testRouter()
+	domain, cookie := testBrandAndCookie(t, r)
+	do := brandClient(t, r, domain, cookie)
+
+	name := fmt.Sprintf("video_%d", time.Now().UnixNano()%1e9)
+	typeBody := fmt.Sprintf(`{"name":%q,"schema":{"fields":{"src":{"type":"string","required":true,"format":"url"},"autoplay":{"type":"boolean"}}},"default_config":{"autoplay":true}}`, name)
+	if w := do(http.MethodPost, "/widget-types", typeBody); w.Code != http.StatusCreated {
+		t.Fatalf("create widget type: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/widget-types", `{"name":"banner","schema":{"fields":{}}}`); w.Code != http.StatusConflict {
+		t.Errorf("register built-in name: got status %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	route := fmt.Sprintf("/custom-widget-%d", time.Now().UnixNano())
+	pageW := do(http.MethodPost, "/pages", fmt.Sprintf(`{"name":"Custom","route":%q}`, route))
+	var page struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(pageW.Body.Bytes(), &page); err != nil {
+		t.Fatalf("parse page: %v", err)
+	}
+
+	if w := do(http.MethodPost, "/pages/"+page.ID+"/widgets", fmt.Sprintf(`{"type":%q,"position":0}`, name)); w.Code != http.StatusBadRequest {
+		t.Errorf("custom widget without src: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	w := do(http.MethodPost, "/pages/"+page.ID+"/widgets", fmt.Sprintf(`{"type":%q,"position":0,"config":{"src":"https://cdn.example.com/v.mp4"}}`, name))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("custom widget: got status %d, body %s", w.Code, w.Body.String())
+	}
+	var widget struct {
+		Config map[string]interface{} `json:"config"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &widget); err != nil {
+		t.Fatalf("parse widget: %v", err)
+	}
+	if widget.Config["autoplay"] != true {
+		t.Errorf("custom widget config: got %v, want autoplay default applied", widget.Config)
+	}
+}
+
+func TestUpdateWidget_ReplacesConfig(t *testing.T) {
+	r := testRouter()
+	domain, cookie := testBrandAndCookie(t, r)
+	do := brandClient(t, r, domain, cookie)
+	type widget struct {
+		ID       string                 `json:"id"`
+		Type     string                 `json:"type"`
+		Position int                    `json:"position"`
+		Config   map[string]interface{} `json:"config"`
+	}
+
+	w := do(http.MethodPost, "/pages", fmt.Sprintf(`{"name":"Update","route":"/update-%d"}`, time.Now().UnixNano()))
+	var page struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("create page: got status %d, body %s", w.Code, w.Body.String())
+	}
+	w = do(http.MethodPost, "/pages/"+page.ID+"/widgets", `{"type":"text","position":2,"config":{"content":"a","align":"center"}}`)
+	var created widget
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("add widget: got status %d, body %s", w.Code, w.Body.String())
+	}
+
+	w = do(http.MethodPut, "/widgets/"+created.ID, `{"config":{"content":"z"}}`)
+	var updated widget
+	if err := json.Unmarshal(w.Body.Bytes(), &updated); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("update config: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if _, ok := updated.Config["align"]; ok || updated.Config["content"] != "z" {
+		t.Errorf("config after update: got %v, want only content", updated.Config)
+	}
+	if updated.Type != "text" || updated.Position != 2 {
+		t.Errorf("fields left out of the body: got type %q, position %d", updated.Type, updated.Position)
+	}
+	// The config sent is validated on its own, so leaving out a required key fails even though the stored config had it.
+	if w := do(http.MethodPut, "/widgets/"+created.ID, `{"config":{"align":"left"}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("config without required content: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	w = do(http.MethodPut, "/widgets/"+created.ID, `{"position":0}`)
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	if w.Code != http.StatusOK || updated.Position != 0 || updated.Config["content"] != "z" {
+		t.Errorf("update without config: got status %d, body %s", w.Code, w.Body.String())
+	}
+}
+
+func TestReorderWidgets_RequiresCompleteSet(t *testing.T) {
+	r := testRouter()
+	domain, cookie := testBrandAndCookie(t, r)
+	do := brandClient(t, r, domain, cookie)
+
+	route := fmt.Sprintf("/reorder-%d", time.Now().UnixNano())
+	pageW := do(http.MethodPost, "/pages", fmt.Sprintf(`{"name":"Reorder","route":%q}`, route))
+	var page struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(pageW.Body.Bytes(), &page); err != nil {
+		t.Fatalf("parse page: %v", err)
+	}
+	var ids []string
+	for i := 0; i < 3; i++ {
+		w := do(http.MethodPost, "/pages/"+page.ID+"/widgets", fmt.Sprintf(`{"type":"spacer","position":%d,"config":{"height":10}}`, i))
+		var widget struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &widget); err != nil || w.Code != http.StatusCreated {
+			t.Fatalf("add widget: got status %d, body %s", w.Code, w.Body.String())
+		}
+		ids = append(ids, widget.ID)
+	}
+
+	partial := fmt.Sprintf(`{"widget_ids":[%q,%q]}`, ids[2], ids[0])
+	if w := do(http.MethodPost, "/pages/"+page.ID+"/widgets/reorder", partial); w.Code != http.StatusBadRequest {
+		t.Errorf("partial reorder: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	unknown := fmt.Sprintf(`{"widget_ids":[%q,%q,%q,"00000000-0000-0000-0000-000000000001"]}`, ids[2], ids[0], ids[1])
+	if w := do(http.MethodPost, "/pages/"+page.ID+"/widgets/reorder", unknown); w.Code != http.StatusBadRequest {
+		t.Errorf("reorder with unknown ID: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	full := fmt.Sprintf(`{"widget_ids":[%q,%q,%q]}`, ids[2], ids[0], ids[1])
+	w := do(http.MethodPost, "/pages/"+page.ID+"/widgets/reorder", full)
+	if w.Code != http.StatusOK {
+		t.Fatalf("reorder: got status %d, body %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Widgets []struct {
+			ID       string `json:"id"`
+			Position int    `json:"position"`
+		} `json:"widgets"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("parse reorder: %v", err)
+	}
+	want := []string{ids[2], ids[0], ids[1]}
+	if len(resp.Widgets) != len(want) {
+		t.Fatalf("reorder widgets: got %d, want %d", len(resp.Widgets), len(want))
+	}
+	for i, id := range want {
+		if resp.Widgets[i].ID != id || resp.Widgets[i].Position != i {
+			t.Errorf("widget %d: got %+v, want id %s at position %d", i, resp.Widgets[i], id, i)
+		}
+	}
+}
+
+func TestBrandSettingsAndDeletion(t *testing.T) {
+	r := testRouter()
+	domain := fmt.Sprintf("settings-%d", time.Now().UnixNano())
+	email := "owner@" + domain + ".com"
+	createBody := fmt.Sprintf(`{"name":"Settings","domain":%q,"email":%q,"password":"secret"}`, domain, email)
+	if w := doRequest(t, r, http.MethodPost, "/brands", createBody); w.Code != http.StatusCreated {
+		t.Fatalf("create brand: got status %d, body %s", w.Code, w.Body.String())
+	}
+	cookie := testLogin(t, r, domain, email, "secret")
+	do := brandClient(t, r, domain, cookie)
+
+	if w := do(http.MethodPut, "/brands/me", `{"domain":"not a domain"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid domain: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := do(http.MethodPut, "/brands/me", `{"name":""}`); w.Code != http.StatusBadRequest {
+		t.Errorf("empty name: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	newDomain := domain + "-renamed"
+	w := do(http.MethodPut, "/brands/me", fmt.Sprintf(`{"name":"Renamed","logo":"https://cdn.example.com/logo.png","domain":%q}`, strings.ToUpper(newDomain)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("update brand: got status %d, body %s", w.Code, w.Body.String())
+	}
+	var brand struct {
+		Name   string `json:"name"`
+		Domain string `json:"domain"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &brand); err != nil || brand.Name != "Renamed" || brand.Domain != newDomain {
+		t.Fatalf("update brand: got %s", w.Body.String())
+	}
+	domain = newDomain
+	do = brandClient(t, r, domain, cookie)
+
+	if w := do(http.MethodPut, "/users/me/password", `{"current_password":"wrong","new_password":"better"}`); w.Code != http.StatusForbidden {
+		t.Errorf("wrong current password: got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	otherSession := testLogin(t, r, domain, email, "secret")
+	if w := do(http.MethodPut, "/users/me/password", `{"current_password":"secret","new_password":"better"}`); w.Code != http.StatusOK {
+		t.Fatalf("change password: got status %d, body %s", w.Code, w.Body.String())
+	}
+	// The session that changed the password stays signed in; every other one is signed out.
+	if w := do(http.MethodGet, "/users/me", ""); w.Code != http.StatusOK {
+		t.Errorf("current session after password change: got status %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := do(http.MethodGet, "/users/me", "", withSession(otherSession)); w.Code != http.StatusUnauthorized {
+		t.Errorf("other session after password change: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := do(http.MethodPost, "/refresh", "", withSession(otherSession)); w.Code != http.StatusUnauthorized {
+		t.Errorf("other session's refresh token after password change: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	cookie = testLogin(t, r, domain, email, "better")
+	do = brandClient(t, r, domain, cookie)
+
+	if w := do(http.MethodPost, "/pages", `{"name":"Home","route":"/","is_home":true}`); w.Code != http.StatusCreated {
+		t.Fatalf("create page: got status %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/brands/me", `{"password":"secret"}`); w.Code != http.StatusForbidden {
+		t.Errorf("delete with wrong password: got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := do(http.MethodDelete, "/brands/me", `{"password":"better"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("delete brand: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/brands/me", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET /brands/me after delete: got status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestCustomDomain_TXTVerification(t *testing.T) {
+	r, h := testRouterWithHandler()
+	resolver := dnsverify.StaticResolver{}
+	h.Resolver = resolver
+	domain, cookie := testBrandAndCookie(t, r)
+	do := brandClient(t, r, domain, cookie)
+	publicApp := func(host string) int {
+		req := httptest.NewRequest(http.MethodGet, "/public/app", nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if w := do(http.MethodPost, "/domains", `{"hostname":"localhost"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("single-label hostname: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	host := fmt.Sprintf("shop%d.example.com", time.Now().UnixNano())
+	w := do(http.MethodPost, "/domains", fmt.Sprintf(`{"hostname":%q}`, strings.ToUpper(host)+"."))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("add domain: got status %d, body %s", w.Code, w.Body.String())
+	}
+	var added struct {
+		ID           string `json:"id"`
+		Hostname     string `json:"hostname"`
+		Verification struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"verification"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &added); err != nil || added.Hostname != host {
+		t.Fatalf("add domain: got %s", w.Body.String())
+	}
+	if added.Verification.Name != "_appdrop-challenge."+host {
+		t.Errorf("challenge name: got %q", added.Verification.Name)
+	}
+
+	if code := publicApp(host); code != http.StatusNotFound {
+		t.Errorf("unverified host: got status %d, want %d", code, http.StatusNotFound)
+	}
+	if w := do(http.MethodPost, "/domains/"+added.ID+"/verify", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("verify without record: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	resolver[added.Verification.Name] = []string{"v=spf1 -all", added.Verification.Value}
+	if w := do(http.MethodPost, "/domains/"+added.ID+"/verify", ""); w.Code != http.StatusOK {
+		t.Fatalf("verify: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if code := publicApp(host + ":8090"); code != http.StatusOK {
+		t.Errorf("verified host: got status %d, want %d", code, http.StatusOK)
+	}
+
+	if w := do(http.MethodDelete, "/domains/"+added.ID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("delete domain: got status %d", w.Code)
+	}
+	if code := publicApp(host); code != http.StatusNotFound {
+		t.Errorf("removed host: got status %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestBrandCache_InvalidationAndMetrics(t *testing.T) {
+	r := testRouter()
+	domain, cookie := testBrandAndCookie(t, r)
+	do := brandClient(t, r, domain, cookie)
+
+	for i := 0; i < 2; i++ {
+		if w := do(http.MethodGet, "/public/app", ""); w.Code != http.StatusOK {
+			t.Fatalf("public app: got status %d", w.Code)
+		}
+	}
+	newDomain := domain + "-moved"
+	if w := do(http.MethodPut, "/brands/me", fmt.Sprintf(`{"domain":%q}`, newDomain)); w.Code != http.StatusOK {
+		t.Fatalf("update brand: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/public/app", ""); w.Code != http.StatusNotFound {
+		t.Errorf("old domain after rename: got status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := do(http.MethodGet, "/public/app", "", withBrand(newDomain)); w.Code != http.StatusOK {
+		t.Errorf("new domain after rename: got status %d, want %d", w.Code, http.StatusOK)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("metrics: got status %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, name := range []string{"appdrop_brand_cache_hits_total", "appdrop_brand_cache_misses_total", "appdrop_brand_cache_entries"} {
+		if !strings.Contains(body, "\n"+name+" ") {
+			t.Errorf("metrics: missing %s in %s", name, body)
+		}
+	}
+	if strings.Contains(body, "appdrop_brand_cache_hits_total 0\n") {
+		t.Errorf("metrics: expected cache hits, got %s", body)
+	}
+}
+
+func TestAPIKeys_BearerAuthAndScopes(t *testing.T) {
+	r := testRouter()
+	domain, cookie := testBrandAndCookie(t, r)
+	do := brandClient(t, r, domain, "")
+
+	if w := do(http.MethodPost, "/api-keys", `{"name":"CI","scopes":["api_keys:write"]}`, withSession(cookie)); w.Code != http.StatusBadRequest {
+		t.Errorf("api_keys:write scope: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	w := do(http.MethodPost, "/api-keys", `{"name":"CI","scopes":["pages:read","pages:write","pages:publish"]}`, withSession(cookie))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create key: got status %d, body %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || !strings.HasPrefix(created.Key, "apd_") {
+		t.Fatalf("create key: got %s", w.Body.String())
+	}
+
+	w = do(http.MethodPost, "/pages", `{"name":"From CI","route":"/ci"}`, withBearer(created.Key))
+	var page struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("create page with key: got status %d, body %s", w.Code, w.Body.String())
+	}
+	// A key publishes as itself; there is no user behind it.
+	w = do(http.MethodPost, "/pages/"+page.ID+"/publish", "", withBearer(created.Key))
+	var published struct {
+		PublishedBy         *string `json:"published_by"`
+		PublishedByAPIKeyID string  `json:"published_by_api_key_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &published); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("publish with key: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if published.PublishedBy != nil || published.PublishedByAPIKeyID != created.ID {
+		t.Errorf("publish with key: got %s, want published_by null and published_by_api_key_id %s", w.Body.String(), created.ID)
+	}
+	if w := do(http.MethodGet, "/users", "", withBearer(created.Key)); w.Code != http.StatusForbidden {
+		t.Errorf("out-of-scope request: got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := do(http.MethodGet, "/api-keys", "", withBearer(created.Key)); w.Code != http.StatusForbidden {
+		t.Errorf("key listing keys: got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	wrongSecret := created.Key[:len(created.Key)-1] + "0"
+	if strings.HasSuffix(created.Key, "0") {
+		wrongSecret = created.Key[:len(created.Key)-1] + "1"
+	}
+	if w := do(http.MethodGet, "/pages", "", withBearer(wrongSecret)); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong secret: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	w = do(http.MethodGet, "/api-keys", "", withSession(cookie))
+	var keys []struct {
+		ID         string  `json:"id"`
+		LastUsedAt *string `json:"last_used_at"`
+		KeyHash    *string `json:"key_hash"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &keys); err != nil || len(keys) != 1 {
+		t.Fatalf("list keys: got %s", w.Body.String())
+	}
+	if keys[0].LastUsedAt == nil || keys[0].KeyHash != nil {
+		t.Errorf("list keys: want last_used_at set and no hash, got %s", w.Body.String())
+	}
+
+	if w := do(http.MethodDelete, "/api-keys/"+created.ID, "", withSession(cookie)); w.Code != http.StatusNoContent {
+		t.Fatalf("revoke key: got status %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/pages", "", withBearer(created.Key)); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked key: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestSessions_RefreshRotationAndRevocation(t *testing.T) {
+	r := testRouter()
+	domain, first := testBrandAndCookie(t, r)
+	do := brandClient(t, r, domain, "")
+
+	w := do(http.MethodPost, "/refresh", "", withSession(first))
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: got status %d, body %s", w.Code, w.Body.String())
+	}
+	rotated := cookieHeader(w)
+	if w := do(http.MethodGet, "/users/me", "", withSession(first)); w.Code != http.StatusUnauthorized {
+		t.Errorf("access token replaced by refresh: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := do(http.MethodGet, "/users/me", "", withSession(rotated)); w.Code != http.StatusOK {
+		t.Fatalf("rotated access token: got status %d", w.Code)
+	}
+
+	// Replaying the old refresh token revokes the whole session, including the tokens just issued.
+	if w := do(http.MethodPost, "/refresh", "", withSession(first)); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh token reuse: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := do(http.MethodPost, "/refresh", "", withSession(rotated)); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after reuse: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := do(http.MethodGet, "/users/me", "", withSession(rotated)); w.Code != http.StatusUnauthorized {
+		t.Errorf("access after reuse: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	a := testLogin(t, r, domain, "test@testbrand.com", "secret")
+	b := testLogin(t, r, domain, "test@testbrand.com", "secret")
+	currentSession := func(cookie string) string {
+		w := do(http.MethodGet, "/sessions", "", withSession(cookie))
+		var sessions []struct {
+			ID      string `json:"id"`
+			Current bool   `json:"current"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &sessions); err != nil {
+			t.Fatalf("list sessions: got status %d, body %s", w.Code, w.Body.String())
+		}
+		for _, s := range sessions {
+			if s.Current {
+				return s.ID
+			}
+		}
+		t.Fatalf("list sessions: no current session in %s", w.Body.String())
+		return ""
+	}
+	other := currentSession(b)
+	if other == currentSession(a) {
+		t.Fatal("two logins share a session")
+	}
+	if w := do(http.MethodDelete, "/sessions/"+other, "", withSession(a)); w.Code != http.StatusNoContent {
+		t.Fatalf("revoke session: got status %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/users/me", "", withSession(b)); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked session: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	if w := do(http.MethodPost, "/logout", "", withSession(a)); w.Code != http.StatusOK {
+		t.Fatalf("logout: got status %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/users/me", "", withSession(a)); w.Code != http.StatusUnauthorized {
+		t.Errorf("access after logout: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+// slowReads delays session and user reads made outside a Tx, so a handler that checks a token or code
+// before it holds the row would let concurrent requests spend it twice.
+type slowReads struct{ store.Store }
+
+func (s slowReads) Sessions() store.SessionStore { return slowSessions{s.Store.Sessions()} }
+func (s slowReads) Users() store.UserStore       { return slowUsers{s.Store.Users()} }
+
+type slowSessions struct{ store.SessionStore }
+
+func (s slowSessions) Get(ctx context.Context, brandID, id uuid.UUID) (*models.Session, error) {
+	session, err := s.SessionStore.Get(ctx, brandID, id)
+	time.Sleep(10 * time.Millisecond)
+	return session, err
+}
+
+type slowUsers struct{ store.UserStore }
+
+func (s slowUsers) Get(ctx context.Context, brandID, id uuid.UUID) (*models.User, error) {
+	user, err := s.UserStore.Get(ctx, brandID, id)
+	time.Sleep(10 * time.Millisecond)
+	return user, err
+}
+
+// Concurrent refreshes with the same refresh token rotate the session once; the rest count as reuse.
+func TestSessions_ConcurrentRefresh(t *testing.T) {
+	r, h := testRouterWithHandler()
+	domain, cookie := testBrandAndCookie(t, r)
+	h.Store = slowReads{h.Store}
+
+	const n = 8
+	codes := make([]int, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = doRequest(t, r, http.MethodPost, "/refresh", "", withBrand(domain), withSession(cookie)).Code
+		}()
+	}
+	wg.Wait()
+	succeeded := 0
+	for _, code := range codes {
+		if code == http.StatusOK {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("concurrent refreshes: %d succeeded, want 1 (codes %v)", succeeded, codes)
+	}
+	if w := doRequest(t, r, http.MethodPost, "/refresh", "", withBrand(domain), withSession(cookie)); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after concurrent reuse: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestUpdateUser_RoleChangeRevokesSessions(t *testing.T) {
+	r := testRouter()
+	domain, cookie := testBrandAndCookie(t, r)
+	do := brandClient(t, r, domain, cookie)
+
+	email := fmt.Sprintf("editor-%d@testbrand.com", time.Now().UnixNano())
+	w := do(http.MethodPost, "/users", fmt.Sprintf(`{"email":%q,"password":"editor","role":"editor"}`, email))
+	var user struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("create editor: got status %d, body %s", w.Code, w.Body.String())
+	}
+	editor := testLogin(t, r, domain, email, "editor")
+	if w := do(http.MethodPut, "/users/"+user.ID, `{"role":"editor"}`); w.Code != http.StatusOK {
+		t.Fatalf("update with the same role: got status %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/users/me", "", withSession(editor)); w.Code != http.StatusOK {
+		t.Errorf("session after an update that kept the role: got status %d, want %d", w.Code, http.StatusOK)
+	}
+
+	if w := do(http.MethodPut, "/users/"+user.ID, `{"role":"viewer"}`); w.Code != http.StatusOK {
+		t.Fatalf("demote editor: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/users/me", "", withSession(editor)); w.Code != http.StatusUnauthorized {
+		t.Errorf("session from before the role change: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := do(http.MethodPost, "/refresh", "", withSession(editor)); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh token from before the role change: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := do(http.MethodGet, "/users/me", ""); w.Code != http.StatusOK {
+		t.Errorf("owner session after changing another user's role: got status %d", w.Code)
+	}
+}
+
+func TestPasswordResetAndEmailVerification(t *testing.T) {
+	r, h := testRouterWithHandler()
+	outbox := h.Mailer.(*mailer.Memory)
+	domain := fmt.Sprintf("recover-%d", time.Now().UnixNano())
+	email := "owner@" + domain + ".com"
+	do := brandClient(t, r, domain, "")
+	// Without APP_URL the token is the last line of the mail.
+	mailedToken := func(subject string) string {
+		msg, ok := outbox.Last(email)
+		if !ok || msg.Subject != subject {
+			t.Fatalf("expected %q mail to %s, got %+v", subject, email, msg)
+		}
+		lines := strings.Split(strings.TrimSpace(msg.Body), "\n")
+		return lines[len(lines)-1]
+	}
+
+	if w := do(http.MethodPost, "/brands", fmt.Sprintf(`{"name":"Recover","domain":%q,"email":%q,"password":"secret"}`, domain, email)); w.Code != http.StatusCreated {
+		t.Fatalf("create brand: got status %d", w.Code)
+	}
+	verifyToken := mailedToken("Verify your email address")
+	if w := do(http.MethodPost, "/email/verify", `{"token":"nope"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("bogus verification token: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := do(http.MethodPost, "/email/verify", fmt.Sprintf(`{"token":%q}`, verifyToken)); w.Code != http.StatusOK {
+		t.Fatalf("verify email: got status %d, body %s", w.Code, w.Body.String())
+	}
+	oldSession := testLogin(t, r, domain, email, "secret")
+	var me struct {
+		EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	}
+	if w := do(http.MethodGet, "/users/me", "", withSession(oldSession)); json.Unmarshal(w.Body.Bytes(), &me) != nil || me.EmailVerifiedAt == nil {
+		t.Errorf("users/me after verification: got %s", w.Body.String())
+	}
+
+	sent := len(outbox.Sent())
+	if w := do(http.MethodPost, "/password/forgot", `{"email":"nobody@example.com"}`); w.Code != http.StatusAccepted {
+		t.Errorf("forgot for unknown email: got status %d, want %d", w.Code, http.StatusAccepted)
+	}
+	if len(outbox.Sent()) != sent {
+		t.Error("mail sent for an unknown email")
+	}
+	if w := do(http.MethodPost, "/password/forgot", fmt.Sprintf(`{"email":%q}`, strings.ToUpper(email))); w.Code != http.StatusAccepted {
+		t.Fatalf("forgot: got status %d", w.Code)
+	}
+	resetToken := mailedToken("Reset your password")
+	reset := fmt.Sprintf(`{"token":%q,"new_password":"fresh"}`, resetToken)
+	if w := do(http.MethodPost, "/password/reset", reset); w.Code != http.StatusOK {
+		t.Fatalf("reset: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/password/reset", reset); w.Code != http.StatusBadRequest {
+		t.Errorf("reused reset token: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := do(http.MethodGet, "/users/me", "", withSession(oldSession)); w.Code != http.StatusUnauthorized {
+		t.Errorf("session from before reset: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	testLogin(t, r, domain, email, "fresh")
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	r, h := testRouterWithHandler()
+	domain := fmt.Sprintf("totp-%d", time.Now().UnixNano())
+	email := "owner@" + domain + ".com"
+	do := brandClient(t, r, domain, "")
+	if w := do(http.MethodPost, "/brands", fmt.Sprintf(`{"name":"TOTP","domain":%q,"email":%q,"password":"secret"}`, domain, email)); w.Code != http.StatusCreated {
+		t.Fatalf("create brand: got status %d", w.Code)
+	}
+	cookie := testLogin(t, r, domain, email, "secret")
+
+	w := do(http.MethodPost, "/users/me/2fa/setup", "", withSession(cookie))
+	var setup struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &setup); err != nil || setup.Secret == "" || !strings.HasPrefix(setup.OTPAuthURI, "otpauth://totp/") {
+		t.Fatalf("setup: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/users/me/2fa/confirm", `{"code":"000000"}`, withSession(cookie)); w.Code != http.StatusBadRequest {
+		t.Errorf("confirm with wrong code: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	now := time.Now()
+	code, _ := auth.TOTPCode(setup.Secret, now)
+	w = do(http.MethodPost, "/users/me/2fa/confirm", fmt.Sprintf(`{"code":%q}`, code), withSession(cookie))
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &confirmed); err != nil || len(confirmed.RecoveryCodes) != 10 {
+		t.Fatalf("confirm: got status %d, body %s", w.Code, w.Body.String())
+	}
+
+	login := func() string {
+		w := do(http.MethodPost, "/login", fmt.Sprintf(`{"email":%q,"password":"secret"}`, email))
+		var resp struct {
+			TwoFactorRequired bool   `json:"two_factor_required"`
+			Challenge         string `json:"challenge"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || !resp.TwoFactorRequired || resp.Challenge == "" {
+			t.Fatalf("login with 2FA: got status %d, body %s", w.Code, w.Body.String())
+		}
+		if cookieHeader(w) != "" {
+			t.Fatal("login set a session cookie before the second factor")
+		}
+		return resp.Challenge
+	}
+	challenge := login()
+	if w := do(http.MethodGet, "/users/me", "", withSession("session="+challenge)); w.Code != http.StatusUnauthorized {
+		t.Errorf("challenge used as session: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	// The confirmation code was consumed; the next time step's code is still inside the accepted window.
+	if w := do(http.MethodPost, "/login/2fa", fmt.Sprintf(`{"challenge":%q,"code":%q}`, challenge, code)); w.Code != http.StatusUnauthorized {
+		t.Errorf("replayed code: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	next, _ := auth.TOTPCode(setup.Secret, now.Add(30*time.Second))
+	w = do(http.MethodPost, "/login/2fa", fmt.Sprintf(`{"challenge":%q,"code":%q}`, challenge, next))
+	if w.Code != http.StatusOK {
+		t.Fatalf("login/2fa with code: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/users/me", "", withSession(cookieHeader(w))); w.Code != http.StatusOK {
+		t.Errorf("session after 2FA: got status %d", w.Code)
+	}
+
+	recovery := fmt.Sprintf(`{"challenge":%q,"recovery_code":%q}`, login(), confirmed.RecoveryCodes[0])
+	if w := do(http.MethodPost, "/login/2fa", recovery); w.Code != http.StatusOK {
+		t.Fatalf("login/2fa with recovery code: got status %d, body %s", w.Code, w.Body.String())
+	}
+	recovery = fmt.Sprintf(`{"challenge":%q,"recovery_code":%q}`, login(), confirmed.RecoveryCodes[0])
+	if w := do(http.MethodPost, "/login/2fa", recovery); w.Code != http.StatusUnauthorized {
+		t.Errorf("reused recovery code: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	// Concurrent logins can't both spend one recovery code.
+	challenges := []string{login(), login()}
+	h.Store = slowReads{h.Store}
+	codes := make([]int, len(challenges))
+	var wg sync.WaitGroup
+	for i, challenge := range challenges {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = do(http.MethodPost, "/login/2fa", fmt.Sprintf(`{"challenge":%q,"recovery_code":%q}`, challenge, confirmed.RecoveryCodes[1])).Code
+		}()
+	}
+	wg.Wait()
+	if slices.Sort(codes); !slices.Equal(codes, []int{http.StatusOK, http.StatusUnauthorized}) {
+		t.Errorf("concurrent logins with one recovery code: got statuses %v, want one %d and one %d", codes, http.StatusOK, http.StatusUnauthorized)
+	}
+
+	if w := do(http.MethodDelete, "/users/me/2fa", `{"password":"secret"}`, withSession(cookie)); w.Code != http.StatusNoContent {
+		t.Fatalf("disable 2FA: got status %d", w.Code)
+	}
+	testLogin(t, r, domain, email, "secret")
+}
+
+func TestLogin_LockoutAfterRepeatedFailures(t *testing.T) {
+	r := testRouter()
+	domain, _ := testBrandAndCookie(t, r)
+	login := func(email, password string) *httptest.ResponseRecorder {
+		return doRequest(t, r, http.MethodPost, "/login", fmt.Sprintf(`{"email":%q,"password":%q}`, email, password), withBrand(domain))
+	}
+
+	for i := 0; i < 5; i++ {
+		if w := login("test@testbrand.com", "wrong"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failed attempt %d: got status %d, want %d", i+1, w.Code, http.StatusUnauthorized)
+		}
+	}
+	w := login("test@testbrand.com", "secret")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("locked account: got status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	var resp handlers.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error.Code != "TOO_MANY_ATTEMPTS" {
+		t.Errorf("locked account: got body %s", w.Body.String())
+	}
+	if secs, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || secs <= 0 {
+		t.Errorf("Retry-After: got %q", w.Header().Get("Retry-After"))
+	}
+	// Other accounts are only limited per IP, which allows more failures.
+	if w := login("someone-else@testbrand.com", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("other account: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestLogin_LockoutIgnoresForwardedFor(t *testing.T) {
+	r := testRouter()
+	domain, _ := testBrandAndCookie(t, r)
+	// Each attempt claims a new client IP and targets a new account, so only the real IP ties them together.
+	login := func(i int) int {
+		body := fmt.Sprintf(`{"email":"guess-%d@testbrand.com","password":"wrong"}`, i)
+		return doRequest(t, r, http.MethodPost, "/login", body, withBrand(domain), withHeader("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i))).Code
+	}
+	for i := range throttle.DefaultIPPolicy.Threshold {
+		if code := login(i); code != http.StatusUnauthorized {
+			t.Fatalf("failed attempt %d: got status %d, want %d", i+1, code, http.StatusUnauthorized)
+		}
+	}
+	if code := login(throttle.DefaultIPPolicy.Threshold); code != http.StatusTooManyRequests {
+		t.Errorf("spoofed X-Forwarded-For after %d failures: got status %d, want %d", throttle.DefaultIPPolicy.Threshold, code, http.StatusTooManyRequests)
+	}
+}
+
+func TestCSRF_RequiredForCookieWrites(t *testing.T) {
+	r := testRouter()
+	domain, cookie := testBrandAndCookie(t, r)
+	pages := 0
+	createPage := func(cookie, csrf, authz string) int {
+		pages++
+		body := fmt.Sprintf(`{"name":"Page %d","route":"/p%d"}`, pages, pages)
+		return doRequest(t, r, http.MethodPost, "/pages", body, withBrand(domain), withCookie(cookie), withCSRF(csrf), withAuthorization(authz)).Code
+	}
+	csrfFrom := func(cookie string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		setSession(req, cookie)
+		return req.Header.Get(auth.CSRFHeader)
+	}
+
+	if code := createPage(cookie, "", ""); code != http.StatusForbidden {
+		t.Errorf("no CSRF header: got status %d, want %d", code, http.StatusForbidden)
+	}
+	if code := createPage(cookie, "forged", ""); code != http.StatusForbidden {
+		t.Errorf("wrong CSRF header: got status %d, want %d", code, http.StatusForbidden)
+	}
+	if code := createPage(cookie, csrfFrom(cookie), ""); code != http.StatusCreated {
+		t.Errorf("valid CSRF header: got status %d, want %d", code, http.StatusCreated)
+	}
+	// A token from another session is useless, even for the same user.
+	other := testLogin(t, r, domain, "test@testbrand.com", "secret")
+	if code := createPage(cookie, csrfFrom(other), ""); code != http.StatusForbidden {
+		t.Errorf("CSRF token of another session: got status %d, want %d", code, http.StatusForbidden)
+	}
+	// Reads need no token.
+	if w := doRequest(t, r, http.MethodGet, "/pages", "", withBrand(domain), withCookie(cookie)); w.Code != http.StatusOK {
+		t.Errorf("GET without CSRF header: got status %d", w.Code)
+	}
+
+	// API keys are not sent automatically by browsers, so they need no token.
+	w := doRequest(t, r, http.MethodPost, "/api-keys", `{"name":"ci","scopes":["pages:write"]}`, withBrand(domain), withSession(cookie))
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || created.Key == "" {
+		t.Fatalf("create API key: %d %s", w.Code, w.Body.String())
+	}
+	if code := createPage("", "", "Bearer "+created.Key); code != http.StatusCreated {
+		t.Errorf("API key without CSRF header: got status %d, want %d", code, http.StatusCreated)
+	}
+
+	// /refresh and /logout check the token against the session, outside the protected routes.
+	for _, path := range []string{"/refresh", "/logout"} {
+		if w := doRequest(t, r, http.MethodPost, path, "", withBrand(domain), withCookie(other)); w.Code != http.StatusForbidden {
+			t.Errorf("%s without CSRF header: got status %d, want %d", path, w.Code, http.StatusForbidden)
+		}
+	}
+	if w := doRequest(t, r, http.MethodPost, "/refresh", "", withBrand(domain), withSession(other)); w.Code != http.StatusOK {
+		t.Errorf("/refresh with CSRF header: got status %d, body %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSSO_OIDCLogin(t *testing.T) {
+	r := testRouter()
+	domain, cookie := testBrandAndCookie(t, r)
+	iss := oidctest.NewIssuer("appdrop", "client-secret")
+	defer iss.Close()
+	do := brandClient(t, r, domain, "")
+	configure := func(body string) {
+		t.Helper()
+		if w := do(http.MethodPut, "/sso/oidc", body, withSession(cookie)); w.Code != http.StatusOK {
+			t.Fatalf("configure SSO: got status %d, body %s", w.Code, w.Body.String())
+		}
+	}
+	configure(fmt.Sprintf(`{"issuer":%q,"client_id":"appdrop","client_secret":"client-secret","redirect_url":"https://testbrand.example/sso/oidc/callback"}`, iss.URL))
+	if w := do(http.MethodGet, "/sso/oidc", "", withSession(cookie)); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "client-secret") {
+		t.Errorf("get SSO settings: got status %d, body %s", w.Code, w.Body.String())
+	}
+
+	// ssoLogin walks the browser through the redirect to the issuer and back to the callback.
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	ssoLogin := func(id oidctest.Identity) (callback string, stateCookie string) {
+		t.Helper()
+		iss.SignIn(id)
+		w := do(http.MethodGet, "/sso/oidc/login", "")
+		if w.Code != http.StatusFound || !strings.HasPrefix(w.Header().Get("Location"), iss.URL+"/authorize?") {
+			t.Fatalf("sso login: got status %d, location %q", w.Code, w.Header().Get("Location"))
+		}
+		resp, err := noRedirect.Get(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		back, _ := url.Parse(resp.Header.Get("Location"))
+		return "/sso/oidc/callback?" + back.RawQuery, cookieHeader(w)
+	}
+
+	callback, state := ssoLogin(oidctest.Identity{Subject: "owner-sub", Email: "Test@TestBrand.com", EmailVerified: true})
+	w := do(http.MethodGet, callback, "", withSession(state))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"role":"owner"`) {
+		t.Fatalf("sso callback: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if me := do(http.MethodGet, "/users/me", "", withSession(cookieHeader(w))); me.Code != http.StatusOK {
+		t.Errorf("session from SSO: got status %d", me.Code)
+	}
+	if w := do(http.MethodGet, callback, "", withSession(state)); w.Code != http.StatusUnauthorized {
+		t.Errorf("replayed callback: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	callback, _ = ssoLogin(oidctest.Identity{Subject: "owner-sub", Email: "test@testbrand.com", EmailVerified: true})
+	if w := do(http.MethodGet, callback, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("callback without the state cookie: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	// The subject is linked now, so a changed email at the provider still signs in the same user.
+	callback, state = ssoLogin(oidctest.Identity{Subject: "owner-sub", Email: "renamed@example.com", EmailVerified: true})
+	if w := do(http.MethodGet, callback, "", withSession(state)); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"role":"owner"`) {
+		t.Errorf("linked identity: got status %d, body %s", w.Code, w.Body.String())
+	}
+
+	newcomer := oidctest.Identity{Subject: "new-sub", Email: "newcomer@testbrand.com", EmailVerified: true}
+	callback, state = ssoLogin(newcomer)
+	if w := do(http.MethodGet, callback, "", withSession(state)); w.Code != http.StatusForbidden {
+		t.Errorf("unknown user without default role: got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	// Leaving out client_secret keeps the stored one.
+	configure(fmt.Sprintf(`{"issuer":%q,"client_id":"appdrop","redirect_url":"https://testbrand.example/sso/oidc/callback","default_role":"editor"}`, iss.URL))
+	callback, state = ssoLogin(newcomer)
+	if w := do(http.MethodGet, callback, "", withSession(state)); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"role":"editor"`) {
+		t.Errorf("provisioned user: got status %d, body %s", w.Code, w.Body.String())
+	}
+	callback, state = ssoLogin(oidctest.Identity{Subject: "unverified", Email: "someone@testbrand.com"})
+	if w := do(http.MethodGet, callback, "", withSession(state)); w.Code != http.StatusForbidden {
+		t.Errorf("unverified email: got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestToken_BearerAccessAndPrecedence(t *testing.T) {
+	r := testRouter()
+	domain, ownerCookie := testBrandAndCookie(t, r)
+	do := brandClient(t, r, domain, "")
+	type tokens struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		RefreshToken string `json:"refresh_token"`
+		SessionID    string `json:"session_id"`
+	}
+	grant := func(body string) (tokens, *httptest.ResponseRecorder) {
+		w := do(http.MethodPost, "/token", body)
+		var tok tokens
+		json.Unmarshal(w.Body.Bytes(), &tok)
+		return tok, w
+	}
+
+	tok, w := grant(`{"grant_type":"password","email":"test@testbrand.com","password":"secret"}`)
+	if w.Code != http.StatusOK || tok.AccessToken == "" || tok.TokenType != "Bearer" || len(w.Result().Cookies()) != 0 {
+		t.Fatalf("password grant: got status %d, body %s, cookies %v", w.Code, w.Body.String(), w.Result().Cookies())
+	}
+	if _, w := grant(`{"grant_type":"password","email":"test@testbrand.com","password":"wrong"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: got status %d", w.Code)
+	}
+	if _, w := grant(`{"grant_type":"client_credentials"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown grant: got status %d", w.Code)
+	}
+	bearer := "Bearer " + tok.AccessToken
+	// Bearer requests need no CSRF token.
+	if w := do(http.MethodPost, "/pages", `{"name":"Mobile","route":"/mobile"}`, withAuthorization(bearer)); w.Code != http.StatusCreated {
+		t.Errorf("POST /pages with bearer token: got status %d, body %s", w.Code, w.Body.String())
+	}
+
+	// The Authorization header wins over the cookie: a viewer's cookie doesn't demote the owner's token,
+	// and a bad header isn't rescued by a valid cookie.
+	email := fmt.Sprintf("viewer-%d@testbrand.com", time.Now().UnixNano())
+	if w := do(http.MethodPost, "/users", fmt.Sprintf(`{"email":%q,"password":"viewer","role":"viewer"}`, email), withSession(ownerCookie)); w.Code != http.StatusCreated {
+		t.Fatalf("create viewer: got status %d", w.Code)
+	}
+	viewerCookie := testLogin(t, r, domain, email, "viewer")
+	if w := do(http.MethodPost, "/pages", `{"name":"Both","route":"/both"}`, withAuthorization(bearer), withSession(viewerCookie)); w.Code != http.StatusCreated {
+		t.Errorf("bearer and cookie: got status %d, want the bearer token's %d", w.Code, http.StatusCreated)
+	}
+	if w := do(http.MethodGet, "/users/me", "", withAuthorization("Bearer not-a-token"), withSession(ownerCookie)); w.Code != http.StatusUnauthorized {
+		t.Errorf("invalid bearer with valid cookie: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := do(http.MethodGet, "/users/me", "", withAuthorization("Basic dXNlcjpwYXNz"), withSession(ownerCookie)); w.Code != http.StatusUnauthorized {
+		t.Errorf("non-bearer Authorization: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	refreshed, w := grant(fmt.Sprintf(`{"grant_type":"refresh_token","refresh_token":%q}`, tok.RefreshToken))
+	if w.Code != http.StatusOK || refreshed.AccessToken == "" || refreshed.RefreshToken == tok.RefreshToken {
+		t.Fatalf("refresh grant: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/users/me", "", withAuthorization(bearer)); w.Code != http.StatusUnauthorized {
+		t.Errorf("access token replaced by refresh: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	bearer = "Bearer " + refreshed.AccessToken
+	if w := do(http.MethodDelete, "/sessions/"+refreshed.SessionID, "", withAuthorization(bearer)); w.Code != http.StatusNoContent {
+		t.Fatalf("revoke own session: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/users/me", "", withAuthorization(bearer)); w.Code != http.StatusUnauthorized {
+		t.Errorf("token of revoked session: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestJWKS_VerifiesSessionToken(t *testing.T) {
+	r := testRouter()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("jwks: got status %d", w.Code)
+	}
+	var doc auth.JWKS
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil || len(doc.Keys) == 0 {
+		t.Fatalf("jwks body: %s", w.Body.String())
+	}
+	token, _, err := auth.CreateToken(uuid.New(), "u1", uuid.New(), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, _ := jwt.NewParser().ParseUnverified(token, &auth.Claims{})
+	kid, _ := parsed.Header["kid"].(string)
+	for _, k := range doc.Keys {
+		if k.Kid == kid {
+			if k.Alg != parsed.Method.Alg() {
+				t.Errorf("jwks alg %q, token alg %q", k.Alg, parsed.Method.Alg())
+			}
+			return
+		}
+	}
+	t.Errorf("token kid %q not published in %s", kid, w.Body.String())
+}
+
+func TestAuditLog_RecordsChangesAndFilters(t *testing.T) {
+	r := testRouter()
+	domain, cookie := testBrandAndCookie(t, r)
+	do := brandClient(t, r, domain, cookie)
+	type auditPage struct {
+		Data []struct {
+			Action      string `json:"action"`
+			EntityType  string `json:"entity_type"`
+			ActorUserID string `json:"actor_user_id"`
+			RequestID   string `json:"request_id"`
+			Changes     map[string]struct {
+				Before any `json:"before"`
+				After  any `json:"after"`
+			} `json:"changes"`
+		} `json:"data"`
+		Total int `json:"total"`
+	}
+	list := func(query string) auditPage {
+		t.Helper()
+		w := do(http.MethodGet, "/audit?"+query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /audit?%s: got status %d, body %s", query, w.Code, w.Body.String())
+		}
+		var resp auditPage
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	route := fmt.Sprintf("/audited-%d", time.Now().UnixNano())
+	w := do(http.MethodPost, "/pages", fmt.Sprintf(`{"name":"Before","route":%q}`, route), withHeader("X-Request-ID", "req-create"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create page: got status %d", w.Code)
+	}
+	if w.Header().Get("X-Request-ID") != "req-create" {
+		t.Errorf("X-Request-ID not echoed: %q", w.Header().Get("X-Request-ID"))
+	}
+	var page struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if w := do(http.MethodPut, "/pages/"+page.ID, `{"name":"After"}`, withHeader("X-Request-ID", "req-update")); w.Code != http.StatusOK {
+		t.Fatalf("update page: got status %d", w.Code)
+	}
+
+	events := list("entity_type=page&entity_id=" + page.ID)
+	if events.Total != 2 || len(events.Data) != 2 {
+		t.Fatalf("page events: got %+v", events)
+	}
+	update, create := events.Data[0], events.Data[1]
+	if update.Action != "update" || create.Action != "create" {
+		t.Fatalf("want newest first, got %s then %s", update.Action, create.Action)
+	}
+	if name := update.Changes["name"]; name.Before != "Before" || name.After != "After" || len(update.Changes) != 1 {
+		t.Errorf("update diff: got %+v", update.Changes)
+	}
+	if create.Changes["route"].After != route || create.Changes["route"].Before != nil {
+		t.Errorf("create diff: got %+v", create.Changes)
+	}
+	if update.ActorUserID == "" || update.RequestID != "req-update" {
+		t.Errorf("actor %q, request ID %q", update.ActorUserID, update.RequestID)
+	}
+
+	if got := list("entity_id=" + page.ID + "&actor_id=" + uuid.NewString()); got.Total != 0 {
+		t.Errorf("other actor: got %d events", got.Total)
+	}
+	if got := list("entity_id=" + page.ID + "&actor_id=" + update.ActorUserID + "&action=update"); got.Total != 1 {
+		t.Errorf("actor and action filter: got %d events", got.Total)
+	}
+	future := url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))
+	if got := list("entity_id=" + page.ID + "&since=" + future); got.Total != 0 {
+		t.Errorf("since in the future: got %d events", got.Total)
+	}
+	if w := do(http.MethodGet, "/audit?since=yesterday", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("bad since: got status %d", w.Code)
+	}
+
+	email := fmt.Sprintf("editor-%d@testbrand.com", time.Now().UnixNano())
+	if w := do(http.MethodPost, "/users", fmt.Sprintf(`{"email":%q,"password":"editor","role":"editor"}`, email)); w.Code != http.StatusCreated {
+		t.Fatalf("create editor: got status %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/audit", "", withSession(testLogin(t, r, domain, email, "editor"))); w.Code != http.StatusForbidden {
+		t.Errorf("editor GET /audit: got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestPageTemplates_CreatePageFromTemplate(t *testing.T) {
+	r := testRouter()
+	domain, cookie := testBrandAndCookie(t, r)
+	do := brandClient(t, r, domain, cookie)
+	type template struct {
+		ID      string `json:"id"`
+		Name    string `json:"name"`
+		Builtin bool   `json:"builtin"`
+	}
+	type page struct {
+		ID      string `json:"id"`
+		Widgets []struct {
+			Type     string                 `json:"type"`
+			Position int                    `json:"position"`
+			Config   map[string]interface{} `json:"config"`
+		} `json:"widgets"`
+	}
+
+	w := do(http.MethodGet, "/page-templates", "")
+	var templates []template
+	json.Unmarshal(w.Body.Bytes(), &templates)
+	if w.Code != http.StatusOK || len(templates) == 0 || !templates[0].Builtin {
+		t.Fatalf("list templates: got status %d, body %s", w.Code, w.Body.String())
+	}
+	global := templates[0]
+	if w := do(http.MethodDelete, "/page-templates/"+global.ID, ""); w.Code != http.StatusForbidden {
+		t.Errorf("delete global template: got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := do(http.MethodPut, "/page-templates/"+global.ID, `{"name":"Mine now"}`); w.Code != http.StatusForbidden {
+		t.Errorf("update global template: got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	w = do(http.MethodPost, "/page-templates", `{"name":"Broken","widgets":[{"type":"carousel"},{"type":"spacer","config":{"height":9999}}]}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "widgets[0].type") || !strings.Contains(w.Body.String(), "widgets[1].config.height") {
+		t.Errorf("invalid template: got status %d, body %s", w.Code, w.Body.String())
+	}
+	name := fmt.Sprintf("Promo %d", time.Now().UnixNano())
+	w = do(http.MethodPost, "/page-templates", fmt.Sprintf(`{"name":%q,"widgets":[{"type":"text","config":{"content":"Sale!"}},{"type":"spacer","config":{"height":16}}]}`, name))
+	var own template
+	json.Unmarshal(w.Body.Bytes(), &own)
+	if w.Code != http.StatusCreated || own.Builtin {
+		t.Fatalf("create template: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/page-templates", fmt.Sprintf(`{"name":%q}`, name)); w.Code != http.StatusConflict {
+		t.Errorf("duplicate template name: got status %d", w.Code)
+	}
+
+	route := fmt.Sprintf("/promo-%d", time.Now().UnixNano())
+	if w := do(http.MethodPost, "/pages?template="+uuid.NewString(), fmt.Sprintf(`{"name":"Promo","route":%q}`, route)); w.Code != http.StatusNotFound {
+		t.Errorf("unknown template: got status %d", w.Code)
+	}
+	w = do(http.MethodPost, "/pages?template="+own.ID, fmt.Sprintf(`{"name":"Promo","route":%q}`, route))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create page from template: got status %d, body %s", w.Code, w.Body.String())
+	}
+	var created page
+	json.Unmarshal(w.Body.Bytes(), &created)
+	w = do(http.MethodGet, "/pages/"+created.ID, "")
+	var stored page
+	json.Unmarshal(w.Body.Bytes(), &stored)
+	if len(stored.Widgets) != 2 || stored.Widgets[0].Type != "text" || stored.Widgets[1].Position != 1 || stored.Widgets[0].Config["content"] != "Sale!" {
+		t.Errorf("page widgets: got %+v", stored.Widgets)
+	}
+
+	// Changing the template later leaves pages made from it alone.
+	if w := do(http.MethodPut, "/page-templates/"+own.ID, `{"widgets":[]}`); w.Code != http.StatusOK {
+		t.Fatalf("update template: got status %d, body %s", w.Code, w.Body.String())
+	}
+	w = do(http.MethodGet, "/pages/"+created.ID, "")
+	json.Unmarshal(w.Body.Bytes(), &stored)
+	if len(stored.Widgets) != 2 {
+		t.Errorf("page changed with its template: %d widgets", len(stored.Widgets))
+	}
+
+	w = do(http.MethodPost, "/pages?template="+global.ID, fmt.Sprintf(`{"name":"Landing","route":"%s-landing"}`, route))
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if w.Code != http.StatusCreated || len(created.Widgets) == 0 {
+		t.Errorf("create page from global template: got status %d, body %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDuplicatePage_CopyAndMoveWidgets(t *testing.T) {
+	r := testRouter()
+	domain, cookie := testBrandAndCookie(t, r)
+	do := brandClient(t, r, domain, cookie)
+	type widget struct {
+		ID       string                 `json:"id"`
+		PageID   string                 `json:"page_id"`
+		Position int                    `json:"position"`
+		Config   map[string]interface{} `json:"config"`
+	}
+	type page struct {
+		ID      string   `json:"id"`
+		Route   string   `json:"route"`
+		Widgets []widget `json:"widgets"`
+	}
+	createPage := func(route string, contents ...string) (page, []widget) {
+		t.Helper()
+		w := do(http.MethodPost, "/pages", fmt.Sprintf(`{"name":"P","route":%q}`, route))
+		var p page
+		json.Unmarshal(w.Body.Bytes(), &p)
+		var widgets []widget
+		for i, content := range contents {
+			w := do(http.MethodPost, "/pages/"+p.ID+"/widgets", fmt.Sprintf(`{"type":"text","position":%d,"config":{"content":%q}}`, i, content))
+			if w.Code != http.StatusCreated {
+				t.Fatalf("add widget: got status %d, body %s", w.Code, w.Body.String())
+			}
+			var wd widget
+			json.Unmarshal(w.Body.Bytes(), &wd)
+			widgets = append(widgets, wd)
+		}
+		return p, widgets
+	}
+
+	base := fmt.Sprintf("/dup-%d", time.Now().UnixNano())
+	a, aWidgets := createPage(base, "one", "two")
+	b, _ := createPage(base+"-b", "b1")
+
+	// contents lists a page's widgets in order. Copies and moves leave both pages numbered 0..n-1.
+	contents := func(pageID string) []string {
+		t.Helper()
+		var p page
+		json.Unmarshal(do(http.MethodGet, "/pages/"+pageID, "").Body.Bytes(), &p)
+		var out []string
+		for i, w := range p.Widgets {
+			if w.Position != i {
+				t.Errorf("page %s: widget %d has position %d", pageID, i, w.Position)
+			}
+			out = append(out, w.Config["content"].(string))
+		}
+		return out
+	}
+
+	w := do(http.MethodPost, "/pages/"+a.ID+"/duplicate", "")
+	var dup page
+	json.Unmarshal(w.Body.Bytes(), &dup)
+	if w.Code != http.StatusCreated || dup.Route != base+"-copy" || len(dup.Widgets) != 2 || dup.Widgets[0].ID == aWidgets[0].ID {
+		t.Fatalf("duplicate: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if got := contents(dup.ID); !slices.Equal(got, []string{"one", "two"}) {
+		t.Errorf("duplicate widgets: got %v", got)
+	}
+	w = do(http.MethodPost, "/pages/"+a.ID+"/duplicate", "")
+	json.Unmarshal(w.Body.Bytes(), &dup)
+	if dup.Route != base+"-copy-2" {
+		t.Errorf("second duplicate route: got %q", dup.Route)
+	}
+	if w := do(http.MethodPost, "/pages/"+a.ID+"/duplicate", fmt.Sprintf(`{"route":%q}`, base+"-b")); w.Code != http.StatusConflict {
+		t.Errorf("duplicate onto a taken route: got status %d", w.Code)
+	}
+
+	if w := do(http.MethodPost, "/widgets/"+aWidgets[1].ID+"/copy", fmt.Sprintf(`{"page_id":%q,"position":0}`, b.ID)); w.Code != http.StatusCreated {
+		t.Fatalf("copy widget: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/widgets/"+aWidgets[0].ID+"/move", fmt.Sprintf(`{"page_id":%q}`, b.ID)); w.Code != http.StatusOK {
+		t.Fatalf("move widget: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if got := contents(b.ID); !slices.Equal(got, []string{"two", "b1", "one"}) {
+		t.Errorf("destination page: got %v", got)
+	}
+	if got := contents(a.ID); !slices.Equal(got, []string{"two"}) {
+		t.Errorf("source page after move: got %v", got)
+	}
+	if w := do(http.MethodPost, "/widgets/"+aWidgets[0].ID+"/move", fmt.Sprintf(`{"page_id":%q,"position":0}`, b.ID)); w.Code != http.StatusOK {
+		t.Fatalf("reposition widget: got status %d", w.Code)
+	}
+	if got := contents(b.ID); !slices.Equal(got, []string{"one", "two", "b1"}) {
+		t.Errorf("after repositioning: got %v", got)
+	}
+
+	// Revision 3 of the source page still holds the moved widget, whose ID is now taken on the destination.
+	if w := do(http.MethodPost, "/pages/"+a.ID+"/revisions/3/restore", ""); w.Code != http.StatusOK {
+		t.Fatalf("restore source page after a move: got status %d, body %s", w.Code, w.Body.String())
+	}
+	if got := contents(a.ID); !slices.Equal(got, []string{"one", "two"}) {
+		t.Errorf("restored source page: got %v", got)
+	}
+	if got := contents(b.ID); !slices.Equal(got, []string{"one", "two", "b1"}) {
+		t.Errorf("destination page after restoring the source: got %v", got)
+	}
+	var restored page
+	json.Unmarshal(do(http.MethodGet, "/pages/"+a.ID, "").Body.Bytes(), &restored)
+	if len(restored.Widgets) != 2 || restored.Widgets[0].ID == aWidgets[0].ID || restored.Widgets[1].ID != aWidgets[1].ID {
+		t.Errorf("restored widget IDs: got %+v, want a new ID for the moved widget and the old one for the other", restored.Widgets)
+	}
+
+	// Another brand's page is not a valid destination, neither by move nor through PUT /widgets/:id.
+	doRequest(t, r, http.MethodPost, "/brands", `{"name":"Other","domain":"otherbrand","email":"owner@otherbrand.com","password":"secret"}`)
+	otherCookie := testLogin(t, r, "otherbrand", "owner@otherbrand.com", "secret")
+	ow := do(http.MethodPost, "/pages", fmt.Sprintf(`{"name":"O","route":%q}`, base), withBrand("otherbrand"), withSession(otherCookie))
+	var other page
+	json.Unmarshal(ow.Body.Bytes(), &other)
+	if other.ID == "" {
+		t.Fatalf("create other brand page: body %s", ow.Body.String())
+	}
+	if w := do(http.MethodPost, "/widgets/"+aWidgets[0].ID+"/move", fmt.Sprintf(`{"page_id":%q}`, other.ID)); w.Code != http.StatusNotFound {
+		t.Errorf("move to another brand's page: got status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	w = do(http.MethodPut, "/widgets/"+aWidgets[0].ID, fmt.Sprintf(`{"type":"text","page_id":%q,"config":{"content":"x"}}`, other.ID))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("PUT /widgets/:id with another page_id: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestMain(m *testing.M) {
+	_ = godotenv.Load()
+	ks, err := auth.EphemeralKeySet()
+	if err != nil {
+		panic(err)
+	}
+	auth.SetKeys(ks)
+	if dsn := os.Getenv("TEST_DATABASE_URL"); dsn != "" {
+		os.Setenv("DATABASE_URL", dsn)
 		db.Connect()
 	}
 	code := m.Run()
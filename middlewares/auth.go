@@ -3,14 +3,37 @@ package middlewares
 import (
 	"APPDROP/auth"
 	"APPDROP/models"
+	"APPDROP/store"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+const (
+	ContextKeyUserID = "user_id"
+	ContextKeyUser   = "user"
+	ContextKeyAPIKey = "api_key"
+	// ContextKeySessionID holds the uuid.UUID of the session behind a cookie-authenticated request.
+	ContextKeySessionID = "session_id"
+	// ContextKeyCSRFHash holds the CSRF hash from the session cookie's access token; RequireCSRF checks it.
+	ContextKeyCSRFHash = "csrf_hash"
+)
+
+// apiKeyTouchInterval limits last-used bookkeeping to one write per key per interval.
+const apiKeyTouchInterval = time.Minute
 
-func RequireAuth() gin.HandlerFunc {
+// RequireAuth accepts, in order of precedence:
+//   - an Authorization header: "Bearer apd_..." is an API key, any other bearer token an access token from
+//     POST /token. When the header is present the cookie is ignored, even if the header is invalid.
+//   - the session cookie set by login.
+//
+// A session sets the user in context; an API key sets the key instead, and acts only within its scopes.
+// Access tokens whose jti is on the revocation list are rejected. Only cookie sessions record the CSRF hash
+// that RequireCSRF checks, since browsers never attach an Authorization header on their own.
+func RequireAuth(users store.UserStore, apiKeys store.APIKeyStore, sessions store.SessionStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		brandVal, exists := c.Get(ContextKeyBrand)
 		if !exists {
@@ -27,6 +50,22 @@ func RequireAuth() gin.HandlerFunc {
 			return
 		}
 
+		if header := c.GetHeader("Authorization"); header != "" {
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			token = strings.TrimSpace(token)
+			switch {
+			case !ok || token == "":
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"error": gin.H{"code": "UNAUTHORIZED", "message": "Authorization header must be \"Bearer <token>\""},
+				})
+			case strings.HasPrefix(token, auth.APIKeyPrefix):
+				authenticateAPIKey(c, apiKeys, brand, token)
+			default:
+				authenticateSession(c, users, sessions, brand, token, false)
+			}
+			return
+		}
+
 		cookie, err := c.Cookie(auth.CookieName())
 		if err != nil || strings.TrimSpace(cookie) == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
@@ -34,24 +73,87 @@ func RequireAuth() gin.HandlerFunc {
 			})
 			return
 		}
+		authenticateSession(c, users, sessions, brand, cookie, true)
+	}
+}
 
-		claims, err := auth.ParseAndValidate(cookie)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": gin.H{"code": "UNAUTHORIZED", "message": "Invalid or expired session"},
-			})
-			return
-		}
+// authenticateSession validates an access token from the session cookie or a bearer header.
+func authenticateSession(c *gin.Context, users store.UserStore, sessions store.SessionStore, brand *models.Brand, token string, fromCookie bool) {
+	claims, err := auth.ParseAndValidate(token)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{"code": "UNAUTHORIZED", "message": "Invalid or expired session"},
+		})
+		return
+	}
+	if revoked, err := sessions.TokenRevoked(c.Request.Context(), claims.ID); err != nil || revoked {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{"code": "UNAUTHORIZED", "message": "Session has been revoked"},
+		})
+		return
+	}
 
-		// claims.BrandID is already uuid.UUID, so no need to parse
-		if claims.BrandID != brand.ID {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
-				"error": gin.H{"code": "FORBIDDEN", "message": "Brand in session does not match this domain"},
-			})
-			return
-		}
+	// claims.BrandID is already uuid.UUID, so no need to parse
+	if claims.BrandID != brand.ID {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": gin.H{"code": "FORBIDDEN", "message": "Brand in session does not match this domain"},
+		})
+		return
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{"code": "UNAUTHORIZED", "message": "Invalid or expired session"},
+		})
+		return
+	}
+	user, err := users.Get(c.Request.Context(), brand.ID, userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{"code": "UNAUTHORIZED", "message": "User for this session no longer exists"},
+		})
+		return
+	}
+
+	c.Set(ContextKeyUserID, user.ID.String())
+	c.Set(ContextKeyUser, user)
+	c.Set(ContextKeySessionID, claims.SessionID)
+	if fromCookie {
+		c.Set(ContextKeyCSRFHash, claims.CSRFHash)
+	}
+	c.Next()
+}
 
-		c.Set("user_id", claims.UserID)
-		c.Next()
+func authenticateAPIKey(c *gin.Context, apiKeys store.APIKeyStore, brand *models.Brand, key string) {
+	ctx := c.Request.Context()
+	prefix, ok := auth.ParseAPIKey(key)
+	var apiKey *models.APIKey
+	if ok {
+		var err error
+		if apiKey, err = apiKeys.GetByPrefix(ctx, prefix); err != nil || !auth.APIKeyMatches(key, apiKey.KeyHash) {
+			ok = false
+		}
+	}
+	now := time.Now()
+	if !ok || !apiKey.Active(now) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{"code": "UNAUTHORIZED", "message": "Invalid, expired or revoked API key"},
+		})
+		return
+	}
+	if apiKey.BrandID != brand.ID {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": gin.H{"code": "FORBIDDEN", "message": "API key does not belong to this domain"},
+		})
+		return
+	}
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval {
+		// Failing to record usage must not fail the request.
+		if err := apiKeys.Touch(ctx, apiKey.ID, now); err == nil {
+			apiKey.LastUsedAt = &now
+		}
 	}
+	c.Set(ContextKeyAPIKey, apiKey)
+	c.Next()
 }
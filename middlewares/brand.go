@@ -1,8 +1,10 @@
 package middlewares
 
 import (
-	"APPDROP/db"
 	"APPDROP/models"
+	"APPDROP/store"
+	"context"
+	"errors"
 	"net/http"
 	"strings"
 
@@ -14,8 +16,23 @@ const (
 	ContextKeyBrand   = "brand"
 )
 
-func BrandResolver() gin.HandlerFunc {
+// BrandResolver identifies the brand for the request: from the X-Brand-Domain header if present, otherwise
+// from a verified custom hostname, falling back to the first subdomain label of the Host.
+// Lookups go through brandCache when it is non-nil.
+func BrandResolver(brands store.BrandStore, domains store.DomainStore, brandCache *BrandCache) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if c.GetHeader("X-Brand-Domain") == "" {
+			if host := requestHost(c); host != "" {
+				if brand := customHostBrand(ctx, brands, domains, brandCache, host); brand != nil {
+					c.Set(ContextKeyBrandID, brand.ID)
+					c.Set(ContextKeyBrand, brand)
+					c.Next()
+					return
+				}
+			}
+		}
+
 		domain := resolveDomain(c)
 		if domain == "" {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
@@ -23,19 +40,54 @@ func BrandResolver() gin.HandlerFunc {
 			})
 			return
 		}
-		var brand models.Brand
-		if err := db.DB.Where("domain = ?", domain).First(&brand).Error; err != nil {
-			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
-				"error": gin.H{"code": "NOT_FOUND", "message": "Brand not found for domain: " + domain},
-			})
-			return
+		brand, found := brandCache.get(domainCacheKey(domain))
+		if !found {
+			var err error
+			brand, err = brands.GetByDomain(ctx, domain)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+					"error": gin.H{"code": "NOT_FOUND", "message": "Brand not found for domain: " + domain},
+				})
+				return
+			}
+			brandCache.set(domainCacheKey(domain), brand)
 		}
 		c.Set(ContextKeyBrandID, brand.ID)
-		c.Set(ContextKeyBrand, &brand)
+		c.Set(ContextKeyBrand, brand)
 		c.Next()
 	}
 }
 
+// customHostBrand returns the brand that verified host, or nil. Misses are cached too, since most
+// requests arrive on subdomains and would otherwise pay for this lookup every time.
+func customHostBrand(ctx context.Context, brands store.BrandStore, domains store.DomainStore, brandCache *BrandCache, host string) *models.Brand {
+	if brand, found := brandCache.get(hostCacheKey(host)); found {
+		return brand
+	}
+	custom, err := domains.GetVerified(ctx, host)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			brandCache.set(hostCacheKey(host), nil)
+		}
+		return nil
+	}
+	brand, err := brands.Get(ctx, custom.BrandID)
+	if err != nil {
+		return nil
+	}
+	brandCache.set(hostCacheKey(host), brand)
+	return brand
+}
+
+// requestHost is the lowercased Host without port or trailing dot.
+func requestHost(c *gin.Context) string {
+	host := c.Request.Host
+	if idx := strings.Index(host, ":"); idx != -1 {
+		host = host[:idx]
+	}
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
 func resolveDomain(c *gin.Context) string {
 	if h := c.GetHeader("X-Brand-Domain"); h != "" {
 		return strings.TrimSpace(strings.ToLower(h))
@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"APPDROP/cache"
+	"APPDROP/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BrandCache memoises BrandResolver lookups so hot brand-scoped reads don't hit the database.
+// Entries are keyed by subdomain ("domain:<d>") or custom hostname ("host:<h>"); a host entry may hold nil
+// to remember that the hostname isn't a verified custom domain. A nil *BrandCache disables caching.
+//
+// Invalidation is local to the process; with several instances, TTL bounds how long others serve stale data.
+type BrandCache struct {
+	lru *cache.LRU[string, *models.Brand]
+}
+
+func NewBrandCache(size int, ttl time.Duration) *BrandCache {
+	return &BrandCache{lru: cache.New[string, *models.Brand](size, ttl)}
+}
+
+func domainCacheKey(domain string) string { return "domain:" + domain }
+func hostCacheKey(host string) string     { return "host:" + host }
+
+// get returns a copy of the cached brand; found is true for negative entries too.
+func (b *BrandCache) get(key string) (brand *models.Brand, found bool) {
+	if b == nil {
+		return nil, false
+	}
+	cached, ok := b.lru.Get(key)
+	if !ok || cached == nil {
+		return nil, ok
+	}
+	copied := *cached
+	return &copied, true
+}
+
+func (b *BrandCache) set(key string, brand *models.Brand) {
+	if b == nil {
+		return
+	}
+	if brand != nil {
+		copied := *brand
+		brand = &copied
+	}
+	b.lru.Set(key, brand)
+}
+
+// InvalidateBrand drops every entry resolving to the brand; call it after the brand is updated or deleted.
+func (b *BrandCache) InvalidateBrand(id uuid.UUID) {
+	if b == nil {
+		return
+	}
+	b.lru.DeleteFunc(func(_ string, brand *models.Brand) bool { return brand != nil && brand.ID == id })
+}
+
+// InvalidateHost drops the entry for a custom hostname; call it when the hostname is verified or removed.
+func (b *BrandCache) InvalidateHost(host string) {
+	if b == nil {
+		return
+	}
+	b.lru.Delete(hostCacheKey(host))
+}
+
+func (b *BrandCache) Stats() cache.Stats {
+	if b == nil {
+		return cache.Stats{}
+	}
+	return b.lru.Stats()
+}
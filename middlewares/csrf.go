@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"APPDROP/auth"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireCSRF runs after RequireAuth. Unsafe requests authenticated by the session cookie must send the
+// CSRF token from login or refresh in the X-CSRF-Token header; browsers attach cookies to cross-site
+// requests, but a foreign page cannot read the token. Requests authenticated by an Authorization header,
+// with an API key or a bearer access token, are not checked: browsers never add that header on their own.
+// POST /refresh and POST /logout sit outside RequireAuth, so they check the token against the session themselves.
+func RequireCSRF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		hash, cookieAuth := c.Get(ContextKeyCSRFHash)
+		if !cookieAuth {
+			c.Next()
+			return
+		}
+		if h, _ := hash.(string); !auth.CSRFTokenMatches(c.GetHeader(auth.CSRFHeader), h) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": gin.H{"code": "FORBIDDEN", "message": "Missing or invalid CSRF token"},
+			})
+			return
+		}
+		c.Next()
+	}
+}
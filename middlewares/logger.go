@@ -20,8 +20,8 @@ func RequestLogger() gin.HandlerFunc {
 		latency := time.Since(start)
 		statusCode = c.Writer.Status()
 
-		log.Printf("[%s] %d %s %s %s (%s)",
-			method, statusCode, path, clientIP, latency, c.Errors.String(),
+		log.Printf("[%s] %d %s %s %s %s (%s)",
+			method, statusCode, path, clientIP, latency, c.GetString(ContextKeyRequestID), c.Errors.String(),
 		)
 	}
 }
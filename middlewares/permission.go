@@ -0,0 +1,63 @@
+package middlewares
+
+import (
+	"APPDROP/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	PermBrandRead        = "brand:read"
+	PermBrandWrite       = "brand:write"
+	PermPagesRead        = "pages:read"
+	PermPagesWrite       = "pages:write"
+	PermPagesPublish     = "pages:publish"
+	PermWidgetsWrite     = "widgets:write"
+	PermWidgetTypesWrite = "widget_types:write"
+	PermUsersRead        = "users:read"
+	PermUsersWrite       = "users:write"
+	PermAPIKeysWrite     = "api_keys:write"
+	PermAuditRead        = "audit:read"
+)
+
+// RolePermissions maps each user role to the permissions it grants.
+var RolePermissions = map[string][]string{
+	models.RoleViewer: {PermBrandRead, PermPagesRead, PermUsersRead},
+	models.RoleEditor: {PermBrandRead, PermPagesRead, PermPagesWrite, PermPagesPublish, PermWidgetsWrite, PermUsersRead},
+	models.RoleOwner:  {PermBrandRead, PermBrandWrite, PermPagesRead, PermPagesWrite, PermPagesPublish, PermWidgetsWrite, PermWidgetTypesWrite, PermUsersRead, PermUsersWrite, PermAPIKeysWrite, PermAuditRead},
+}
+
+func HasPermission(role, permission string) bool {
+	for _, p := range RolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission must run after RequireAuth; it aborts with 403 unless the current user's role, or the
+// API key's scopes, grant permission.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var granted bool
+		if user, ok := c.Value(ContextKeyUser).(*models.User); ok && user != nil {
+			granted = HasPermission(user.Role, permission)
+		} else if key, ok := c.Value(ContextKeyAPIKey).(*models.APIKey); ok && key != nil {
+			granted = key.HasScope(permission)
+		} else {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": gin.H{"code": "UNAUTHORIZED", "message": "Missing or invalid session"},
+			})
+			return
+		}
+		if !granted {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": gin.H{"code": "FORBIDDEN", "message": "Missing permission: " + permission},
+			})
+			return
+		}
+		c.Next()
+	}
+}
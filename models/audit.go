@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audit actions. Creations, updates and deletions use the first three; the rest name changes that are
+// not plain edits.
+const (
+	AuditCreate    = "create"
+	AuditUpdate    = "update"
+	AuditDelete    = "delete"
+	AuditPublish   = "publish"
+	AuditUnpublish = "unpublish"
+	AuditRestore   = "restore"
+	AuditReorder   = "reorder"
+	AuditVerify    = "verify"
+	AuditRevoke    = "revoke"
+	AuditCopy      = "copy"
+	AuditMove      = "move"
+)
+
+// Audited entity types.
+const (
+	AuditEntityBrand        = "brand"
+	AuditEntityPage         = "page"
+	AuditEntityWidget       = "widget"
+	AuditEntityWidgetType   = "widget_type"
+	AuditEntityPageTemplate = "page_template"
+	AuditEntityDomain       = "domain"
+	AuditEntityUser         = "user"
+	AuditEntityAPIKey       = "api_key"
+	AuditEntitySSO          = "sso"
+)
+
+// AuditChange is one field's value before and after a change; nil on the side where the entity did not exist.
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditEvent records who changed what in a brand. Events are only ever appended, and outlive the entities
+// they describe. The actor is the signed-in user or, for API key requests, the key.
+type AuditEvent struct {
+	ID            uuid.UUID              `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	BrandID       uuid.UUID              `gorm:"type:uuid;not null" json:"brand_id"`
+	ActorUserID   *uuid.UUID             `gorm:"type:uuid" json:"actor_user_id,omitempty"`
+	ActorAPIKeyID *uuid.UUID             `gorm:"type:uuid;column:actor_api_key_id" json:"actor_api_key_id,omitempty"`
+	Action        string                 `gorm:"not null" json:"action"`
+	EntityType    string                 `gorm:"not null" json:"entity_type"`
+	EntityID      uuid.UUID              `gorm:"type:uuid;not null" json:"entity_id"`
+	Changes       map[string]AuditChange `gorm:"type:jsonb;serializer:json" json:"changes"`
+	IPAddress     string                 `json:"ip_address"`
+	RequestID     string                 `json:"request_id"`
+	CreatedAt     time.Time              `json:"created_at"`
+}
+
+func (AuditEvent) TableName() string { return "audit_events" }
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BrandDomain is a full custom hostname (e.g. shop.acme.com) a brand serves on.
+// It only routes traffic once the brand has proven ownership through a DNS TXT record.
+type BrandDomain struct {
+	ID                uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	BrandID           uuid.UUID  `gorm:"type:uuid;not null" json:"brand_id"`
+	Hostname          string     `gorm:"not null" json:"hostname"`
+	VerificationToken string     `gorm:"not null" json:"verification_token"`
+	VerifiedAt        *time.Time `json:"verified_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+func (BrandDomain) TableName() string { return "brand_domains" }
+
+func (d BrandDomain) Verified() bool { return d.VerifiedAt != nil }
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OIDCProvider configures OpenID Connect single sign-on for a brand. With DefaultRole set, people the
+// identity provider vouches for but who have no user in the brand yet are created with that role;
+// otherwise SSO only signs in existing users.
+type OIDCProvider struct {
+	BrandID      uuid.UUID `gorm:"type:uuid;primaryKey" json:"brand_id"`
+	Issuer       string    `gorm:"not null" json:"issuer"`
+	ClientID     string    `gorm:"not null" json:"client_id"`
+	ClientSecret string    `json:"-"`
+	RedirectURL  string    `gorm:"not null" json:"redirect_url"`
+	DefaultRole  string    `json:"default_role,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (OIDCProvider) TableName() string { return "oidc_providers" }
+
+// UserIdentity links a brand user to the subject an OIDC issuer knows them by.
+type UserIdentity struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	BrandID   uuid.UUID `gorm:"type:uuid;not null" json:"brand_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Issuer    string    `gorm:"not null" json:"issuer"`
+	Subject   string    `gorm:"not null" json:"subject"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (UserIdentity) TableName() string { return "user_identities" }
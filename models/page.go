@@ -6,13 +6,20 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	PageStatusDraft     = "draft"
+	PageStatusPublished = "published"
+)
+
 type Page struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	BrandID   uuid.UUID `gorm:"type:uuid;not null" json:"brand_id"`
-	Name      string    `json:"name"`
-	Route     string    `json:"route"`
-	IsHome    bool      `json:"is_home"`
-	Widgets   []Widget  `gorm:"foreignKey:PageID" json:"widgets,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	BrandID     uuid.UUID  `gorm:"type:uuid;not null" json:"brand_id"`
+	Name        string     `json:"name"`
+	Route       string     `json:"route"`
+	IsHome      bool       `json:"is_home"`
+	Status      string     `gorm:"not null;default:draft" json:"status"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	Widgets     []Widget   `gorm:"foreignKey:PageID" json:"widgets,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RevisionPageCreated      = "page.created"
+	RevisionPageUpdated      = "page.updated"
+	RevisionWidgetAdded      = "widget.added"
+	RevisionWidgetUpdated    = "widget.updated"
+	RevisionWidgetDeleted    = "widget.deleted"
+	RevisionWidgetsReordered = "widgets.reordered"
+	RevisionRestored         = "revision.restored"
+	RevisionPageDuplicated   = "page.duplicated"
+	RevisionWidgetMoved      = "widget.moved"
+)
+
+// PageRevision is an immutable record of a page and its widgets right after a change.
+type PageRevision struct {
+	ID        uuid.UUID    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	PageID    uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_page_revisions_page_number" json:"page_id"`
+	BrandID   uuid.UUID    `gorm:"type:uuid;not null;index" json:"brand_id"`
+	Number    int          `gorm:"not null;uniqueIndex:idx_page_revisions_page_number" json:"number"`
+	Action    string       `gorm:"not null" json:"action"`
+	UserID    *uuid.UUID   `gorm:"type:uuid" json:"user_id,omitempty"`
+	Snapshot  PageSnapshot `gorm:"type:jsonb;serializer:json" json:"snapshot"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+func (PageRevision) TableName() string { return "page_revisions" }
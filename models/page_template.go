@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TemplateWidget is one widget of a template, in page order. Config is merged over the widget type's
+// defaults when a page is created from the template.
+type TemplateWidget struct {
+	Type   string                 `json:"type"`
+	Config map[string]interface{} `json:"config,omitempty"`
+}
+
+// PageTemplate is a page layout new pages can start from. Brands register their own; global templates
+// are built in, shared by every brand and read-only.
+type PageTemplate struct {
+	ID          uuid.UUID        `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	BrandID     uuid.UUID        `gorm:"type:uuid;not null;uniqueIndex:idx_page_templates_brand_name" json:"brand_id"`
+	Name        string           `gorm:"not null;uniqueIndex:idx_page_templates_brand_name" json:"name"`
+	Description string           `json:"description"`
+	Widgets     []TemplateWidget `gorm:"type:jsonb;serializer:json" json:"widgets"`
+	Builtin     bool             `gorm:"-" json:"builtin"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+func (PageTemplate) TableName() string { return "page_templates" }
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PageSnapshot is a frozen copy of a page and its widgets, ordered by position.
+type PageSnapshot struct {
+	Name    string   `json:"name"`
+	Route   string   `json:"route"`
+	IsHome  bool     `json:"is_home"`
+	Widgets []Widget `json:"widgets"`
+}
+
+// PublishedPage is the live version of a page. Drafts in pages/widgets never leak into it until republished.
+type PublishedPage struct {
+	PageID   uuid.UUID    `gorm:"type:uuid;primaryKey" json:"page_id"`
+	BrandID  uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_published_pages_brand_route" json:"brand_id"`
+	Route    string       `gorm:"not null;uniqueIndex:idx_published_pages_brand_route" json:"route"`
+	IsHome   bool         `json:"is_home"`
+	Snapshot PageSnapshot `gorm:"type:jsonb;serializer:json" json:"snapshot"`
+	// PublishedBy is the user who published the page, or nil when an API key did, set in PublishedByAPIKeyID.
+	PublishedBy         *uuid.UUID `gorm:"type:uuid" json:"published_by"`
+	PublishedByAPIKeyID *uuid.UUID `gorm:"type:uuid" json:"published_by_api_key_id,omitempty"`
+	PublishedAt         time.Time  `json:"published_at"`
+}
+
+func (PublishedPage) TableName() string { return "published_pages" }
+
+// NewPageSnapshot copies page and widgets into a snapshot.
+func NewPageSnapshot(page Page, widgets []Widget) PageSnapshot {
+	if widgets == nil {
+		widgets = []Widget{}
+	}
+	return PageSnapshot{
+		Name:    page.Name,
+		Route:   page.Route,
+		IsHome:  page.IsHome,
+		Widgets: widgets,
+	}
+}
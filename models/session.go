@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a server-side login. It holds the hash of the current refresh token and the jti of the
+// access token issued with it, so both can be revoked, and the hash of the current CSRF token, so /refresh
+// and /logout can check it without a valid access token.
+type Session struct {
+	ID               uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	BrandID          uuid.UUID  `gorm:"type:uuid;not null;index" json:"brand_id"`
+	UserID           uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	RefreshTokenHash string     `gorm:"not null" json:"-"`
+	AccessTokenID    string     `gorm:"not null" json:"-"`
+	CSRFTokenHash    string     `gorm:"not null;default:''" json:"-"`
+	UserAgent        string     `json:"user_agent"`
+	IPAddress        string     `json:"ip_address"`
+	ExpiresAt        time.Time  `gorm:"not null" json:"expires_at"`
+	RefreshedAt      *time.Time `json:"refreshed_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+func (Session) TableName() string { return "sessions" }
+
+// Active reports whether the session can still be refreshed at time now.
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// RevokedToken is an access token jti that must be rejected until the token would have expired anyway.
+type RevokedToken struct {
+	JTI       string    `gorm:"column:jti;primaryKey"`
+	ExpiresAt time.Time `gorm:"not null"`
+}
+
+func (RevokedToken) TableName() string { return "revoked_tokens" }
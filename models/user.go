@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// User is a login for a brand. TOTPSecret is stored at 2FA setup but only enforced once TOTPEnabledAt is set;
+// TOTPLastStep records the time step of the last accepted code so a code can't be replayed.
+type User struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	BrandID            uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_users_brand_email" json:"brand_id"`
+	Email              string     `gorm:"not null;uniqueIndex:idx_users_brand_email" json:"email"`
+	PasswordHash       string     `json:"-"` // Never return password hash in JSON
+	Role               string     `gorm:"not null;default:viewer" json:"role"`
+	EmailVerifiedAt    *time.Time `json:"email_verified_at,omitempty"`
+	TOTPSecret         string     `gorm:"column:totp_secret" json:"-"`
+	TOTPEnabledAt      *time.Time `gorm:"column:totp_enabled_at" json:"totp_enabled_at,omitempty"`
+	TOTPLastStep       int64      `gorm:"column:totp_last_step" json:"-"`
+	RecoveryCodeHashes []string   `gorm:"type:jsonb;serializer:json" json:"-"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+func (User) TableName() string { return "users" }
+
+// IsValidRole reports whether r is one of the known user roles.
+func IsValidRole(r string) bool {
+	switch r {
+	case RoleOwner, RoleEditor, RoleViewer:
+		return true
+	}
+	return false
+}
+
+// TwoFactorEnabled reports whether logins need a TOTP or recovery code after the password.
+func (u User) TwoFactorEnabled() bool { return u.TOTPEnabledAt != nil }
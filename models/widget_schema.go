@@ -0,0 +1,29 @@
+package models
+
+const (
+	FieldString  = "string"
+	FieldNumber  = "number"
+	FieldInteger = "integer"
+	FieldBoolean = "boolean"
+	FieldArray   = "array"
+	FieldObject  = "object"
+
+	FormatURL = "url"
+)
+
+// FieldSchema describes one key of a widget config.
+// Min/Max bound the value for numbers and the length for strings and arrays.
+type FieldSchema struct {
+	Type     string   `json:"type"`
+	Required bool     `json:"required,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	Enum     []string `json:"enum,omitempty"`
+	Format   string   `json:"format,omitempty"`
+	Items    string   `json:"items,omitempty"`
+}
+
+// ConfigSchema is the typed definition of a widget type's config. Keys not listed are allowed and left unchecked.
+type ConfigSchema struct {
+	Fields map[string]FieldSchema `json:"fields"`
+}
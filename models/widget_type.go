@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WidgetType is a brand-registered widget type, usable alongside the built-in ones.
+type WidgetType struct {
+	ID            uuid.UUID              `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	BrandID       uuid.UUID              `gorm:"type:uuid;not null;uniqueIndex:idx_widget_types_brand_name" json:"brand_id"`
+	Name          string                 `gorm:"not null;uniqueIndex:idx_widget_types_brand_name" json:"name"`
+	DisplayName   string                 `json:"display_name"`
+	Description   string                 `json:"description"`
+	Icon          string                 `json:"icon"`
+	Schema        ConfigSchema           `gorm:"type:jsonb;serializer:json" json:"schema"`
+	DefaultConfig map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"default_config,omitempty"`
+	Builtin       bool                   `gorm:"-" json:"builtin"`
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+}
+
+func (WidgetType) TableName() string { return "widget_types" }
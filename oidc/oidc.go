@@ -0,0 +1,276 @@
+// Package oidc implements the relying-party side of the OpenID Connect authorization code flow with PKCE:
+// discovery, the authorization request, the code exchange and ID token validation.
+package oidc
+
+import (
+	"APPDROP/cache"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// discoveryTTL bounds how long a provider's metadata and keys are reused before being fetched again.
+const discoveryTTL = time.Hour
+
+// clockSkew is the leeway allowed on ID token times.
+const clockSkew = time.Minute
+
+// Provider is an issuer's discovery document.
+type Provider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Client talks to identity providers, caching discovery documents and signing keys per issuer.
+type Client struct {
+	HTTP      *http.Client
+	providers *cache.LRU[string, *Provider]
+	keys      *cache.LRU[string, map[string]any]
+}
+
+// NewClient returns a Client using httpClient, or a client with a 10 second timeout when it is nil.
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{
+		HTTP:      httpClient,
+		providers: cache.New[string, *Provider](100, discoveryTTL),
+		keys:      cache.New[string, map[string]any](100, discoveryTTL),
+	}
+}
+
+// ValidateIssuer requires an https issuer URL, allowing plain http only for loopback hosts used in development.
+func ValidateIssuer(issuer string) error {
+	u, err := url.Parse(issuer)
+	if err != nil || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("issuer must be an absolute URL")
+	}
+	if u.Scheme == "https" {
+		return nil
+	}
+	if ip := net.ParseIP(u.Hostname()); u.Scheme == "http" && (u.Hostname() == "localhost" || ip != nil && ip.IsLoopback()) {
+		return nil
+	}
+	return errors.New("issuer must use https")
+}
+
+// Discover loads the issuer's /.well-known/openid-configuration, which must name the same issuer.
+func (c *Client) Discover(ctx context.Context, issuer string) (*Provider, error) {
+	if p, ok := c.providers.Get(issuer); ok {
+		return p, nil
+	}
+	if err := ValidateIssuer(issuer); err != nil {
+		return nil, err
+	}
+	var p Provider
+	if err := c.getJSON(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &p); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if p.Issuer != issuer {
+		return nil, fmt.Errorf("discovery: document is for issuer %q", p.Issuer)
+	}
+	if p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" || p.JWKSURI == "" {
+		return nil, errors.New("discovery: missing authorization, token or jwks endpoint")
+	}
+	c.providers.Set(issuer, &p)
+	return &p, nil
+}
+
+// Config is what a relying party is registered with at the provider.
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// NewPKCE returns a code verifier and its S256 challenge (RFC 7636).
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// NewState returns a random value for the state or nonce parameter.
+func NewState() (string, error) { return randomString(24) }
+
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// AuthCodeURL is where to send the browser to sign in.
+func (p *Provider) AuthCodeURL(cfg Config, state, nonce, challenge string) string {
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.ClientID},
+		"redirect_uri":          {cfg.RedirectURL},
+		"scope":                 {"openid email profile"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// Exchange redeems an authorization code and returns the raw ID token. Confidential clients authenticate
+// with client_secret_basic; public clients rely on PKCE alone.
+func (c *Client) Exchange(ctx context.Context, p *Provider, cfg Config, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if cfg.ClientSecret == "" {
+		form.Set("client_id", cfg.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("token endpoint: status %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token endpoint: status %d: %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token endpoint: no id_token in response")
+	}
+	return body.IDToken, nil
+}
+
+// IDToken holds the claims AppDrop uses from a validated ID token.
+type IDToken struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Nonce         string `json:"nonce"`
+	AuthorizedFor string `json:"azp"`
+	jwt.RegisteredClaims
+}
+
+// Verify checks the ID token's signature against the issuer's published keys, then its issuer, audience,
+// expiry and nonce (OpenID Connect Core 3.1.3.7).
+func (c *Client) Verify(ctx context.Context, p *Provider, clientID, raw, nonce string) (*IDToken, error) {
+	claims := &IDToken{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return c.key(ctx, p, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("id token: %w", err)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("id token: no subject")
+	}
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, errors.New("id token: nonce mismatch")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedFor != clientID {
+		return nil, errors.New("id token: azp does not match the client")
+	}
+	return claims, nil
+}
+
+// key finds the issuer's signing key by kid, refetching the key set once when the kid is unknown
+// so the provider can rotate keys.
+func (c *Client) key(ctx context.Context, p *Provider, kid string) (any, error) {
+	keys, cached := c.keys.Get(p.JWKSURI)
+	if k, ok := keys[kid]; ok {
+		return k, nil
+	}
+	if cached {
+		c.keys.Delete(p.JWKSURI)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := c.getJSON(ctx, p.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	keys = make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if pub, err := k.publicKey(); err == nil {
+			keys[k.Kid] = pub
+		}
+	}
+	c.keys.Set(p.JWKSURI, keys)
+	if k, ok := keys[kid]; ok {
+		return k, nil
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, k := range keys {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("jwks: no key %q", kid)
+}
+
+func (c *Client) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,89 @@
+package oidc_test
+
+import (
+	"APPDROP/oidc"
+	"APPDROP/oidc/oidctest"
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestAuthorizationCodeFlowWithPKCE(t *testing.T) {
+	iss := oidctest.NewIssuer("client-1", "s3cret")
+	defer iss.Close()
+	iss.SignIn(oidctest.Identity{Subject: "alice", Email: "alice@example.com", EmailVerified: true})
+
+	ctx := context.Background()
+	client := oidc.NewClient(nil)
+	p, err := client.Discover(ctx, iss.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := oidc.Config{ClientID: "client-1", ClientSecret: "s3cret", RedirectURL: "https://app.example/callback"}
+	verifier, challenge, _ := oidc.NewPKCE()
+	state, _ := oidc.NewState()
+	nonce, _ := oidc.NewState()
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noRedirect.Get(p.AuthCodeURL(cfg, state, nonce, challenge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, _ := url.Parse(resp.Header.Get("Location"))
+	if callback.Query().Get("state") != state {
+		t.Fatalf("state not echoed: %s", callback)
+	}
+	code := callback.Query().Get("code")
+
+	if _, err := client.Exchange(ctx, p, cfg, code, "wrong-verifier"); err == nil {
+		t.Error("exchange with the wrong PKCE verifier succeeded")
+	}
+	// The failed attempt consumed the code, as a real provider may; authorize again.
+	resp, _ = noRedirect.Get(p.AuthCodeURL(cfg, state, nonce, challenge))
+	resp.Body.Close()
+	callback, _ = url.Parse(resp.Header.Get("Location"))
+	raw, err := client.Exchange(ctx, p, cfg, callback.Query().Get("code"), verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := client.Verify(ctx, p, cfg.ClientID, raw, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Subject != "alice" || id.Email != "alice@example.com" || !id.EmailVerified {
+		t.Errorf("claims: %+v", id)
+	}
+	if _, err := client.Verify(ctx, p, cfg.ClientID, raw, "other-nonce"); err == nil {
+		t.Error("ID token accepted with the wrong nonce")
+	}
+}
+
+func TestVerifyRejectsOtherAudience(t *testing.T) {
+	iss := oidctest.NewIssuer("client-1", "")
+	defer iss.Close()
+	client := oidc.NewClient(nil)
+	p, err := client.Discover(context.Background(), iss.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := iss.IDToken(oidctest.Identity{Subject: "alice"}, "another-client", "n")
+	if _, err := client.Verify(context.Background(), p, "client-1", raw, "n"); err == nil {
+		t.Error("ID token for another client accepted")
+	}
+}
+
+func TestValidateIssuer(t *testing.T) {
+	for issuer, ok := range map[string]bool{
+		"https://accounts.example.com": true,
+		"http://127.0.0.1:5556":        true,
+		"http://localhost:8080/realm":  true,
+		"http://idp.example.com":       false,
+		"accounts.example.com":         false,
+	} {
+		if err := oidc.ValidateIssuer(issuer); (err == nil) != ok {
+			t.Errorf("ValidateIssuer(%q) = %v", issuer, err)
+		}
+	}
+}
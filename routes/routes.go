@@ -7,34 +7,114 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(r *gin.Engine) {
+func RegisterRoutes(r *gin.Engine, h *handlers.Handler) {
+	s := h.Store
+	resolveBrand := middlewares.BrandResolver(s.Brands(), s.Domains(), h.BrandCache)
+	r.Use(middlewares.RequestID())
+
 	// Public (no brand required)
 	r.GET("/health", func(c *gin.Context) { c.JSON(200, gin.H{"status": "ok"}) })
-	r.POST("/brands", handlers.CreateBrand)
+	r.GET("/metrics", h.Metrics)
+	r.GET("/.well-known/jwks.json", h.JWKS)
+	r.POST("/brands", h.CreateBrand)
+
+	// Public delivery for the storefront app: brand-scoped, no auth, published snapshots only
+	public := r.Group("/public")
+	public.Use(resolveBrand)
+	{
+		public.GET("/app", h.GetPublicApp)
+		public.GET("/pages/*route", h.GetPublicPage)
+	}
 
 	// Brand-scoped (BrandResolver: brand from subdomain or X-Brand-Domain)
 	brandGroup := r.Group("/")
-	brandGroup.Use(middlewares.BrandResolver())
+	brandGroup.Use(resolveBrand)
 	{
 		// Public within brand: login (sets cookie for this brand's domain)
-		brandGroup.POST("/login", handlers.Login)
-		brandGroup.POST("/logout", handlers.Logout)
+		brandGroup.POST("/login", h.Login)
+		brandGroup.POST("/login/2fa", h.LoginTwoFactor)
+		brandGroup.POST("/logout", h.Logout)
+		brandGroup.POST("/refresh", h.Refresh)
+		brandGroup.POST("/token", h.Token)
+		brandGroup.POST("/password/forgot", h.ForgotPassword)
+		brandGroup.POST("/password/reset", h.ResetPassword)
+		brandGroup.POST("/email/verify", h.VerifyEmail)
+		brandGroup.GET("/sso/oidc/login", h.SSOLogin)
+		brandGroup.GET("/sso/oidc/callback", h.SSOCallback)
 
-		// Protected: require valid JWT and brand match
+		// Protected: require a valid session or API key for this brand, and a CSRF token on cookie-authenticated writes
 		protected := brandGroup.Group("/")
-		protected.Use(middlewares.RequireAuth())
+		protected.Use(middlewares.RequireAuth(s.Users(), s.APIKeys(), s.Sessions()), middlewares.RequireCSRF())
 		{
-			protected.POST("/pages", handlers.CreatePages)
-			protected.GET("/pages", handlers.GetPages)
-			protected.GET("/pages/:id", handlers.GetPageByID)
-			protected.PUT("/pages/:id", handlers.UpdatePage)
-			protected.DELETE("/pages/:id", handlers.DeletePage)
-			protected.POST("/pages/:id/widgets", handlers.AddWidget)
-			protected.PUT("/widgets/:id", handlers.UpdateWidget)
-			protected.DELETE("/widgets/:id", handlers.DeleteWidget)
-			protected.POST("/pages/:id/widgets/reorder", handlers.ReorderWidgets)
-			protected.GET("/brands/me", handlers.GetBrandMe)
-			protected.GET("/brands/:id", handlers.GetBrandByID)
+			readPages := middlewares.RequirePermission(middlewares.PermPagesRead)
+			writePages := middlewares.RequirePermission(middlewares.PermPagesWrite)
+			publishPages := middlewares.RequirePermission(middlewares.PermPagesPublish)
+			writeWidgets := middlewares.RequirePermission(middlewares.PermWidgetsWrite)
+			writeWidgetTypes := middlewares.RequirePermission(middlewares.PermWidgetTypesWrite)
+			readBrand := middlewares.RequirePermission(middlewares.PermBrandRead)
+			writeBrand := middlewares.RequirePermission(middlewares.PermBrandWrite)
+			readUsers := middlewares.RequirePermission(middlewares.PermUsersRead)
+			writeUsers := middlewares.RequirePermission(middlewares.PermUsersWrite)
+			writeAPIKeys := middlewares.RequirePermission(middlewares.PermAPIKeysWrite)
+			readAudit := middlewares.RequirePermission(middlewares.PermAuditRead)
+
+			protected.POST("/pages", writePages, h.CreatePages)
+			protected.GET("/pages", readPages, h.GetPages)
+			protected.GET("/pages/:id", readPages, h.GetPageByID)
+			protected.PUT("/pages/:id", writePages, h.UpdatePage)
+			protected.DELETE("/pages/:id", writePages, h.DeletePage)
+			protected.POST("/pages/:id/duplicate", writePages, writeWidgets, h.DuplicatePage)
+			protected.POST("/pages/:id/publish", publishPages, h.PublishPage)
+			protected.POST("/pages/:id/unpublish", publishPages, h.UnpublishPage)
+			protected.GET("/pages/:id/published", readPages, h.GetPublishedPage)
+			protected.GET("/pages/:id/revisions", readPages, h.ListPageRevisions)
+			protected.GET("/pages/:id/revisions/:rev", readPages, h.GetPageRevision)
+			protected.POST("/pages/:id/revisions/:rev/restore", writePages, h.RestorePageRevision)
+			protected.POST("/pages/:id/widgets", writeWidgets, h.AddWidget)
+			protected.PUT("/widgets/:id", writeWidgets, h.UpdateWidget)
+			protected.DELETE("/widgets/:id", writeWidgets, h.DeleteWidget)
+			protected.POST("/widgets/:id/copy", writeWidgets, h.CopyWidget)
+			protected.POST("/widgets/:id/move", writeWidgets, h.MoveWidget)
+			protected.POST("/pages/:id/widgets/reorder", writeWidgets, h.ReorderWidgets)
+			protected.GET("/widget-types", readPages, h.ListWidgetTypes)
+			protected.GET("/widget-types/:id", readPages, h.GetWidgetType)
+			protected.POST("/widget-types", writeWidgetTypes, h.CreateWidgetType)
+			protected.PUT("/widget-types/:id", writeWidgetTypes, h.UpdateWidgetType)
+			protected.DELETE("/widget-types/:id", writeWidgetTypes, h.DeleteWidgetType)
+			protected.GET("/page-templates", readPages, h.ListPageTemplates)
+			protected.GET("/page-templates/:id", readPages, h.GetPageTemplate)
+			protected.POST("/page-templates", writePages, h.CreatePageTemplate)
+			protected.PUT("/page-templates/:id", writePages, h.UpdatePageTemplate)
+			protected.DELETE("/page-templates/:id", writePages, h.DeletePageTemplate)
+			protected.GET("/brands/me", readBrand, h.GetBrandMe)
+			protected.PUT("/brands/me", writeBrand, h.UpdateBrandMe)
+			protected.DELETE("/brands/me", writeBrand, h.DeleteBrandMe)
+			protected.GET("/brands/:id", readBrand, h.GetBrandByID)
+			protected.GET("/domains", readBrand, h.ListDomains)
+			protected.POST("/domains", writeBrand, h.AddDomain)
+			protected.POST("/domains/:id/verify", writeBrand, h.VerifyDomain)
+			protected.DELETE("/domains/:id", writeBrand, h.DeleteDomain)
+			protected.GET("/sso/oidc", writeBrand, h.GetSSO)
+			protected.PUT("/sso/oidc", writeBrand, h.UpdateSSO)
+			protected.DELETE("/sso/oidc", writeBrand, h.DeleteSSO)
+			protected.GET("/users", readUsers, h.ListUsers)
+			protected.GET("/users/me", h.GetUserMe)
+			protected.PUT("/users/me/password", h.ChangePassword)
+			protected.POST("/email/verify/resend", h.ResendEmailVerification)
+			protected.POST("/users/me/2fa/setup", h.SetupTwoFactor)
+			protected.POST("/users/me/2fa/confirm", h.ConfirmTwoFactor)
+			protected.POST("/users/me/2fa/recovery-codes", h.RegenerateRecoveryCodes)
+			protected.DELETE("/users/me/2fa", h.DisableTwoFactor)
+			protected.GET("/users/:id", readUsers, h.GetUserByID)
+			protected.POST("/users", writeUsers, h.CreateUser)
+			protected.PUT("/users/:id", writeUsers, h.UpdateUser)
+			protected.DELETE("/users/:id", writeUsers, h.DeleteUser)
+			protected.GET("/sessions", h.ListSessions)
+			protected.DELETE("/sessions/:id", h.RevokeSession)
+			protected.GET("/api-keys", writeAPIKeys, h.ListAPIKeys)
+			protected.POST("/api-keys", writeAPIKeys, h.CreateAPIKey)
+			protected.DELETE("/api-keys/:id", writeAPIKeys, h.RevokeAPIKey)
+			protected.GET("/audit", readAudit, h.ListAuditEvents)
 		}
 	}
 }
@@ -0,0 +1,1465 @@
+package store
+
+import (
+	"APPDROP/models"
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Memory is a fully in-process Store for tests and local runs. Data is lost when the process exits.
+//
+// A single mutex guards everything: each call outside a transaction takes it briefly, and Tx holds it
+// for the whole callback so transactions are serialised and can be rolled back by restoring a snapshot.
+type Memory struct {
+	mu   sync.Mutex
+	data *memData
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{data: newMemData()}
+}
+
+// memData holds one map per table. Records are copied on the way in and out, so stored values are never
+// mutated in place and a shallow clone of the maps is a consistent snapshot.
+type memData struct {
+	seq            int64
+	order          map[uuid.UUID]int64
+	brands         map[uuid.UUID]models.Brand
+	users          map[uuid.UUID]models.User
+	pages          map[uuid.UUID]models.Page
+	widgets        map[uuid.UUID]models.Widget
+	publishedPages map[uuid.UUID]models.PublishedPage
+	revisions      map[uuid.UUID]models.PageRevision
+	widgetTypes    map[uuid.UUID]models.WidgetType
+	pageTemplates  map[uuid.UUID]models.PageTemplate
+	domains        map[uuid.UUID]models.BrandDomain
+	apiKeys        map[uuid.UUID]models.APIKey
+	sessions       map[uuid.UUID]models.Session
+	revokedTokens  map[string]time.Time
+	userTokens     map[uuid.UUID]models.UserToken
+	oidcProviders  map[uuid.UUID]models.OIDCProvider
+	identities     map[uuid.UUID]models.UserIdentity
+	auditEvents    map[uuid.UUID]models.AuditEvent
+}
+
+func newMemData() *memData {
+	return &memData{
+		order:          map[uuid.UUID]int64{},
+		brands:         map[uuid.UUID]models.Brand{},
+		users:          map[uuid.UUID]models.User{},
+		pages:          map[uuid.UUID]models.Page{},
+		widgets:        map[uuid.UUID]models.Widget{},
+		publishedPages: map[uuid.UUID]models.PublishedPage{},
+		revisions:      map[uuid.UUID]models.PageRevision{},
+		widgetTypes:    map[uuid.UUID]models.WidgetType{},
+		pageTemplates:  map[uuid.UUID]models.PageTemplate{},
+		domains:        map[uuid.UUID]models.BrandDomain{},
+		apiKeys:        map[uuid.UUID]models.APIKey{},
+		sessions:       map[uuid.UUID]models.Session{},
+		revokedTokens:  map[string]time.Time{},
+		userTokens:     map[uuid.UUID]models.UserToken{},
+		oidcProviders:  map[uuid.UUID]models.OIDCProvider{},
+		identities:     map[uuid.UUID]models.UserIdentity{},
+		auditEvents:    map[uuid.UUID]models.AuditEvent{},
+	}
+}
+
+func (d *memData) clone() *memData {
+	return &memData{
+		seq:            d.seq,
+		order:          maps.Clone(d.order),
+		brands:         maps.Clone(d.brands),
+		users:          maps.Clone(d.users),
+		pages:          maps.Clone(d.pages),
+		widgets:        maps.Clone(d.widgets),
+		publishedPages: maps.Clone(d.publishedPages),
+		revisions:      maps.Clone(d.revisions),
+		widgetTypes:    maps.Clone(d.widgetTypes),
+		pageTemplates:  maps.Clone(d.pageTemplates),
+		domains:        maps.Clone(d.domains),
+		apiKeys:        maps.Clone(d.apiKeys),
+		sessions:       maps.Clone(d.sessions),
+		revokedTokens:  maps.Clone(d.revokedTokens),
+		userTokens:     maps.Clone(d.userTokens),
+		oidcProviders:  maps.Clone(d.oidcProviders),
+		identities:     maps.Clone(d.identities),
+		auditEvents:    maps.Clone(d.auditEvents),
+	}
+}
+
+// track assigns an ID if needed and records insertion order, used to break ties between equal timestamps.
+func (d *memData) track(id *uuid.UUID) {
+	if *id == uuid.Nil {
+		*id = uuid.New()
+	}
+	d.seq++
+	d.order[*id] = d.seq
+}
+
+func stamp(createdAt, updatedAt *time.Time) {
+	now := time.Now()
+	if createdAt.IsZero() {
+		*createdAt = now
+	}
+	*updatedAt = now
+}
+
+// memView is the handle every repository works through; inTx means the caller already holds the lock.
+type memView struct {
+	m    *Memory
+	inTx bool
+}
+
+func (v memView) do(fn func(d *memData) error) error {
+	if !v.inTx {
+		v.m.mu.Lock()
+		defer v.m.mu.Unlock()
+	}
+	return fn(v.m.data)
+}
+
+func (m *Memory) view() memView { return memView{m: m} }
+
+func (m *Memory) Brands() BrandStore                 { return memBrands{m.view()} }
+func (m *Memory) Users() UserStore                   { return memUsers{m.view()} }
+func (m *Memory) Pages() PageStore                   { return memPages{m.view()} }
+func (m *Memory) Widgets() WidgetStore               { return memWidgets{m.view()} }
+func (m *Memory) PublishedPages() PublishedPageStore { return memPublished{m.view()} }
+func (m *Memory) Revisions() RevisionStore           { return memRevisions{m.view()} }
+func (m *Memory) WidgetTypes() WidgetTypeStore       { return memWidgetTypes{m.view()} }
+func (m *Memory) PageTemplates() PageTemplateStore   { return memPageTemplates{m.view()} }
+func (m *Memory) Domains() DomainStore               { return memDomains{m.view()} }
+func (m *Memory) APIKeys() APIKeyStore               { return memAPIKeys{m.view()} }
+func (m *Memory) Sessions() SessionStore             { return memSessions{m.view()} }
+func (m *Memory) UserTokens() UserTokenStore         { return memUserTokens{m.view()} }
+func (m *Memory) SSO() SSOStore                      { return memSSO{m.view()} }
+func (m *Memory) Audit() AuditStore                  { return memAudit{m.view()} }
+
+func (m *Memory) Tx(ctx context.Context, fn func(Store) error) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshot := m.data.clone()
+	defer func() {
+		if r := recover(); r != nil {
+			m.data = snapshot
+			panic(r)
+		}
+		if err != nil {
+			m.data = snapshot
+		}
+	}()
+	return fn(memTx{memView{m: m, inTx: true}})
+}
+
+// memTx is the Store handed to a Tx callback.
+type memTx struct{ v memView }
+
+func (t memTx) Brands() BrandStore                 { return memBrands{t.v} }
+func (t memTx) Users() UserStore                   { return memUsers{t.v} }
+func (t memTx) Pages() PageStore                   { return memPages{t.v} }
+func (t memTx) Widgets() WidgetStore               { return memWidgets{t.v} }
+func (t memTx) PublishedPages() PublishedPageStore { return memPublished{t.v} }
+func (t memTx) Revisions() RevisionStore           { return memRevisions{t.v} }
+func (t memTx) WidgetTypes() WidgetTypeStore       { return memWidgetTypes{t.v} }
+func (t memTx) PageTemplates() PageTemplateStore   { return memPageTemplates{t.v} }
+func (t memTx) Domains() DomainStore               { return memDomains{t.v} }
+func (t memTx) APIKeys() APIKeyStore               { return memAPIKeys{t.v} }
+func (t memTx) Sessions() SessionStore             { return memSessions{t.v} }
+func (t memTx) UserTokens() UserTokenStore         { return memUserTokens{t.v} }
+func (t memTx) SSO() SSOStore                      { return memSSO{t.v} }
+func (t memTx) Audit() AuditStore                  { return memAudit{t.v} }
+
+func (t memTx) Tx(ctx context.Context, fn func(Store) error) error {
+	return fn(t)
+}
+
+// first returns a copy of the earliest-inserted record matching match.
+func first[T any](d *memData, table map[uuid.UUID]T, id func(T) uuid.UUID, match func(T) bool) (T, bool) {
+	var found T
+	var foundSeq int64
+	ok := false
+	for _, rec := range table {
+		if !match(rec) {
+			continue
+		}
+		if seq := d.order[id(rec)]; !ok || seq < foundSeq {
+			found, foundSeq, ok = rec, seq, true
+		}
+	}
+	return found, ok
+}
+
+// filter returns the matching records in insertion order.
+func filter[T any](d *memData, table map[uuid.UUID]T, id func(T) uuid.UUID, match func(T) bool) []T {
+	var out []T
+	for _, rec := range table {
+		if match(rec) {
+			out = append(out, rec)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return d.order[id(out[i])] < d.order[id(out[j])] })
+	return out
+}
+
+func copyConfig(config map[string]interface{}) map[string]interface{} {
+	if config == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(config))
+	for k, v := range config {
+		out[k] = copyValue(v)
+	}
+	return out
+}
+
+func copyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return copyConfig(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = copyValue(item)
+		}
+		return out
+	}
+	return v
+}
+
+func copyWidget(w models.Widget) models.Widget {
+	w.Config = copyConfig(w.Config)
+	return w
+}
+
+func copyWidgets(widgets []models.Widget) []models.Widget {
+	if widgets == nil {
+		return nil
+	}
+	out := make([]models.Widget, len(widgets))
+	for i, w := range widgets {
+		out[i] = copyWidget(w)
+	}
+	return out
+}
+
+func copySnapshot(s models.PageSnapshot) models.PageSnapshot {
+	s.Widgets = copyWidgets(s.Widgets)
+	return s
+}
+
+func copyWidgetType(t models.WidgetType) models.WidgetType {
+	t.DefaultConfig = copyConfig(t.DefaultConfig)
+	if t.Schema.Fields != nil {
+		fields := make(map[string]models.FieldSchema, len(t.Schema.Fields))
+		for name, f := range t.Schema.Fields {
+			f.Enum = slices.Clone(f.Enum)
+			fields[name] = f
+		}
+		t.Schema.Fields = fields
+	}
+	return t
+}
+
+type memBrands struct{ v memView }
+
+func brandKey(b models.Brand) uuid.UUID { return b.ID }
+
+func (s memBrands) Create(ctx context.Context, brand *models.Brand) error {
+	return s.v.do(func(d *memData) error {
+		if _, taken := first(d, d.brands, brandKey, func(b models.Brand) bool {
+			return b.Domain == brand.Domain || b.Email == brand.Email
+		}); taken {
+			return ErrConflict
+		}
+		d.track(&brand.ID)
+		stamp(&brand.CreatedAt, &brand.UpdatedAt)
+		d.brands[brand.ID] = *brand
+		return nil
+	})
+}
+
+func (s memBrands) Get(ctx context.Context, id uuid.UUID) (*models.Brand, error) {
+	return s.find(func(b models.Brand) bool { return b.ID == id })
+}
+
+func (s memBrands) GetByDomain(ctx context.Context, domain string) (*models.Brand, error) {
+	return s.find(func(b models.Brand) bool { return b.Domain == domain })
+}
+
+func (s memBrands) GetByEmail(ctx context.Context, email string) (*models.Brand, error) {
+	return s.find(func(b models.Brand) bool { return b.Email == email })
+}
+
+func (s memBrands) find(match func(models.Brand) bool) (*models.Brand, error) {
+	var out models.Brand
+	err := s.v.do(func(d *memData) error {
+		b, ok := first(d, d.brands, brandKey, match)
+		if !ok {
+			return ErrNotFound
+		}
+		out = b
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s memBrands) Update(ctx context.Context, brand *models.Brand) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.brands[brand.ID]; !ok {
+			return ErrNotFound
+		}
+		if _, taken := first(d, d.brands, brandKey, func(b models.Brand) bool {
+			return b.ID != brand.ID && (b.Domain == brand.Domain || b.Email == brand.Email)
+		}); taken {
+			return ErrConflict
+		}
+		stamp(&brand.CreatedAt, &brand.UpdatedAt)
+		d.brands[brand.ID] = *brand
+		return nil
+	})
+}
+
+func (s memBrands) Delete(ctx context.Context, id uuid.UUID) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.brands[id]; !ok {
+			return ErrNotFound
+		}
+		delete(d.brands, id)
+		maps.DeleteFunc(d.users, func(_ uuid.UUID, u models.User) bool { return u.BrandID == id })
+		maps.DeleteFunc(d.widgetTypes, func(_ uuid.UUID, t models.WidgetType) bool { return t.BrandID == id })
+		maps.DeleteFunc(d.pageTemplates, func(_ uuid.UUID, t models.PageTemplate) bool { return t.BrandID == id })
+		maps.DeleteFunc(d.domains, func(_ uuid.UUID, bd models.BrandDomain) bool { return bd.BrandID == id })
+		maps.DeleteFunc(d.apiKeys, func(_ uuid.UUID, k models.APIKey) bool { return k.BrandID == id })
+		maps.DeleteFunc(d.sessions, func(_ uuid.UUID, s models.Session) bool { return s.BrandID == id })
+		maps.DeleteFunc(d.userTokens, func(_ uuid.UUID, t models.UserToken) bool { return t.BrandID == id })
+		delete(d.oidcProviders, id)
+		maps.DeleteFunc(d.identities, func(_ uuid.UUID, i models.UserIdentity) bool { return i.BrandID == id })
+		maps.DeleteFunc(d.publishedPages, func(_ uuid.UUID, p models.PublishedPage) bool { return p.BrandID == id })
+		maps.DeleteFunc(d.revisions, func(_ uuid.UUID, r models.PageRevision) bool { return r.BrandID == id })
+		maps.DeleteFunc(d.widgets, func(_ uuid.UUID, w models.Widget) bool { return d.pages[w.PageID].BrandID == id })
+		maps.DeleteFunc(d.pages, func(_ uuid.UUID, p models.Page) bool { return p.BrandID == id })
+		return nil
+	})
+}
+
+type memUsers struct{ v memView }
+
+func userKey(u models.User) uuid.UUID { return u.ID }
+
+func copyUser(u models.User) models.User {
+	u.RecoveryCodeHashes = slices.Clone(u.RecoveryCodeHashes)
+	return u
+}
+
+func (s memUsers) Create(ctx context.Context, user *models.User) error {
+	return s.v.do(func(d *memData) error {
+		if _, taken := first(d, d.users, userKey, func(u models.User) bool {
+			return u.BrandID == user.BrandID && u.Email == user.Email
+		}); taken {
+			return ErrConflict
+		}
+		d.track(&user.ID)
+		stamp(&user.CreatedAt, &user.UpdatedAt)
+		d.users[user.ID] = copyUser(*user)
+		return nil
+	})
+}
+
+func (s memUsers) Get(ctx context.Context, brandID, id uuid.UUID) (*models.User, error) {
+	return s.find(func(u models.User) bool { return u.ID == id && u.BrandID == brandID })
+}
+
+func (s memUsers) GetByEmail(ctx context.Context, brandID uuid.UUID, email string) (*models.User, error) {
+	return s.find(func(u models.User) bool { return u.BrandID == brandID && u.Email == email })
+}
+
+func (s memUsers) find(match func(models.User) bool) (*models.User, error) {
+	var out models.User
+	err := s.v.do(func(d *memData) error {
+		u, ok := first(d, d.users, userKey, match)
+		if !ok {
+			return ErrNotFound
+		}
+		out = copyUser(u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s memUsers) List(ctx context.Context, brandID uuid.UUID) ([]models.User, error) {
+	var users []models.User
+	err := s.v.do(func(d *memData) error {
+		users = filter(d, d.users, userKey, func(u models.User) bool { return u.BrandID == brandID })
+		for i := range users {
+			users[i] = copyUser(users[i])
+		}
+		return nil
+	})
+	return users, err
+}
+
+func (s memUsers) CountByRole(ctx context.Context, brandID uuid.UUID, role string) (int64, error) {
+	var count int64
+	err := s.v.do(func(d *memData) error {
+		for _, u := range d.users {
+			if u.BrandID == brandID && u.Role == role {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (s memUsers) Update(ctx context.Context, user *models.User) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.users[user.ID]; !ok {
+			return ErrNotFound
+		}
+		if _, taken := first(d, d.users, userKey, func(u models.User) bool {
+			return u.ID != user.ID && u.BrandID == user.BrandID && u.Email == user.Email
+		}); taken {
+			return ErrConflict
+		}
+		stamp(&user.CreatedAt, &user.UpdatedAt)
+		d.users[user.ID] = copyUser(*user)
+		return nil
+	})
+}
+
+func (s memUsers) Lock(ctx context.Context, id uuid.UUID) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.users[id]; !ok {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (s memUsers) Delete(ctx context.Context, brandID, id uuid.UUID) error {
+	return s.v.do(func(d *memData) error {
+		if u, ok := d.users[id]; !ok || u.BrandID != brandID {
+			return ErrNotFound
+		}
+		delete(d.users, id)
+		maps.DeleteFunc(d.sessions, func(_ uuid.UUID, s models.Session) bool { return s.UserID == id })
+		maps.DeleteFunc(d.userTokens, func(_ uuid.UUID, t models.UserToken) bool { return t.UserID == id })
+		maps.DeleteFunc(d.identities, func(_ uuid.UUID, i models.UserIdentity) bool { return i.UserID == id })
+		for keyID, k := range d.apiKeys {
+			if k.CreatedBy != nil && *k.CreatedBy == id {
+				k.CreatedBy = nil
+				d.apiKeys[keyID] = k
+			}
+		}
+		return nil
+	})
+}
+
+type memPages struct{ v memView }
+
+func pageKey(p models.Page) uuid.UUID { return p.ID }
+
+func storedPage(p models.Page) models.Page {
+	p.Widgets = nil
+	return p
+}
+
+func (s memPages) Create(ctx context.Context, page *models.Page) error {
+	return s.v.do(func(d *memData) error {
+		if _, taken := first(d, d.pages, pageKey, func(p models.Page) bool {
+			return p.BrandID == page.BrandID && p.Route == page.Route
+		}); taken {
+			return ErrConflict
+		}
+		d.track(&page.ID)
+		stamp(&page.CreatedAt, &page.UpdatedAt)
+		if page.Status == "" {
+			page.Status = models.PageStatusDraft
+		}
+		d.pages[page.ID] = storedPage(*page)
+		return nil
+	})
+}
+
+func (s memPages) Get(ctx context.Context, brandID, id uuid.UUID) (*models.Page, error) {
+	return s.find(func(p models.Page) bool { return p.ID == id && p.BrandID == brandID })
+}
+
+func (s memPages) GetByRoute(ctx context.Context, brandID uuid.UUID, route string) (*models.Page, error) {
+	return s.find(func(p models.Page) bool { return p.BrandID == brandID && p.Route == route })
+}
+
+func (s memPages) GetHome(ctx context.Context, brandID uuid.UUID) (*models.Page, error) {
+	return s.find(func(p models.Page) bool { return p.BrandID == brandID && p.IsHome })
+}
+
+func (s memPages) find(match func(models.Page) bool) (*models.Page, error) {
+	var out models.Page
+	err := s.v.do(func(d *memData) error {
+		p, ok := first(d, d.pages, pageKey, match)
+		if !ok {
+			return ErrNotFound
+		}
+		out = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s memPages) List(ctx context.Context, brandID uuid.UUID, opts PageListOptions) ([]models.Page, error) {
+	var pages []models.Page
+	err := s.v.do(func(d *memData) error {
+		pages = filter(d, d.pages, pageKey, func(p models.Page) bool { return p.BrandID == brandID })
+		return nil
+	})
+	if opts.Limit > 0 {
+		start := min(opts.Offset, len(pages))
+		end := min(start+opts.Limit, len(pages))
+		pages = pages[start:end]
+	}
+	return pages, err
+}
+
+func (s memPages) Count(ctx context.Context, brandID uuid.UUID) (int64, error) {
+	var count int64
+	err := s.v.do(func(d *memData) error {
+		for _, p := range d.pages {
+			if p.BrandID == brandID {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (s memPages) Update(ctx context.Context, page *models.Page) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.pages[page.ID]; !ok {
+			return ErrNotFound
+		}
+		if _, taken := first(d, d.pages, pageKey, func(p models.Page) bool {
+			return p.ID != page.ID && p.BrandID == page.BrandID && p.Route == page.Route
+		}); taken {
+			return ErrConflict
+		}
+		stamp(&page.CreatedAt, &page.UpdatedAt)
+		d.pages[page.ID] = storedPage(*page)
+		return nil
+	})
+}
+
+func (s memPages) Delete(ctx context.Context, brandID, id uuid.UUID) error {
+	return s.v.do(func(d *memData) error {
+		if p, ok := d.pages[id]; !ok || p.BrandID != brandID {
+			return ErrNotFound
+		}
+		delete(d.pages, id)
+		delete(d.publishedPages, id)
+		maps.DeleteFunc(d.widgets, func(_ uuid.UUID, w models.Widget) bool { return w.PageID == id })
+		maps.DeleteFunc(d.revisions, func(_ uuid.UUID, r models.PageRevision) bool { return r.PageID == id })
+		return nil
+	})
+}
+
+// Lock only checks the page exists: transactions already hold the store-wide lock.
+func (s memPages) Lock(ctx context.Context, id uuid.UUID) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.pages[id]; !ok {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+type memWidgets struct{ v memView }
+
+func widgetKey(w models.Widget) uuid.UUID { return w.ID }
+
+func (s memWidgets) Create(ctx context.Context, widget *models.Widget) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.pages[widget.PageID]; !ok {
+			return ErrNotFound
+		}
+		if _, taken := d.widgets[widget.ID]; taken {
+			return ErrConflict
+		}
+		d.track(&widget.ID)
+		stamp(&widget.CreatedAt, &widget.UpdatedAt)
+		d.widgets[widget.ID] = copyWidget(*widget)
+		return nil
+	})
+}
+
+func (s memWidgets) Get(ctx context.Context, id uuid.UUID) (*models.Widget, error) {
+	var out models.Widget
+	err := s.v.do(func(d *memData) error {
+		w, ok := d.widgets[id]
+		if !ok {
+			return ErrNotFound
+		}
+		out = copyWidget(w)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s memWidgets) ListByPage(ctx context.Context, pageID uuid.UUID, widgetType string) ([]models.Widget, error) {
+	var widgets []models.Widget
+	err := s.v.do(func(d *memData) error {
+		widgets = copyWidgets(filter(d, d.widgets, widgetKey, func(w models.Widget) bool {
+			return w.PageID == pageID && (widgetType == "" || w.Type == widgetType)
+		}))
+		return nil
+	})
+	sort.SliceStable(widgets, func(i, j int) bool { return widgets[i].Position < widgets[j].Position })
+	return widgets, err
+}
+
+func (s memWidgets) Update(ctx context.Context, widget *models.Widget) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.widgets[widget.ID]; !ok {
+			return ErrNotFound
+		}
+		if _, ok := d.pages[widget.PageID]; !ok {
+			return ErrNotFound
+		}
+		stamp(&widget.CreatedAt, &widget.UpdatedAt)
+		d.widgets[widget.ID] = copyWidget(*widget)
+		return nil
+	})
+}
+
+func (s memWidgets) SetPosition(ctx context.Context, id uuid.UUID, position int) error {
+	return s.v.do(func(d *memData) error {
+		w, ok := d.widgets[id]
+		if !ok {
+			return ErrNotFound
+		}
+		w.Position = position
+		w.UpdatedAt = time.Now()
+		d.widgets[id] = w
+		return nil
+	})
+}
+
+func (s memWidgets) Delete(ctx context.Context, id uuid.UUID) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.widgets[id]; !ok {
+			return ErrNotFound
+		}
+		delete(d.widgets, id)
+		return nil
+	})
+}
+
+func (s memWidgets) DeleteByPage(ctx context.Context, pageID uuid.UUID) error {
+	return s.v.do(func(d *memData) error {
+		maps.DeleteFunc(d.widgets, func(_ uuid.UUID, w models.Widget) bool { return w.PageID == pageID })
+		return nil
+	})
+}
+
+func (s memWidgets) CountByType(ctx context.Context, brandID uuid.UUID, widgetType string) (int64, error) {
+	var count int64
+	err := s.v.do(func(d *memData) error {
+		for _, w := range d.widgets {
+			if p, ok := d.pages[w.PageID]; ok && p.BrandID == brandID && w.Type == widgetType {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+type memPublished struct{ v memView }
+
+func publishedKey(p models.PublishedPage) uuid.UUID { return p.PageID }
+
+func (s memPublished) Save(ctx context.Context, published *models.PublishedPage) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.pages[published.PageID]; !ok {
+			return ErrNotFound
+		}
+		if _, taken := first(d, d.publishedPages, publishedKey, func(p models.PublishedPage) bool {
+			return p.PageID != published.PageID && p.BrandID == published.BrandID && p.Route == published.Route
+		}); taken {
+			return ErrConflict
+		}
+		if published.PublishedAt.IsZero() {
+			published.PublishedAt = time.Now()
+		}
+		p := *published
+		p.Snapshot = copySnapshot(p.Snapshot)
+		d.publishedPages[p.PageID] = p
+		return nil
+	})
+}
+
+func (s memPublished) Get(ctx context.Context, brandID, pageID uuid.UUID) (*models.PublishedPage, error) {
+	return s.find(func(p models.PublishedPage) bool { return p.PageID == pageID && p.BrandID == brandID })
+}
+
+func (s memPublished) GetByRoute(ctx context.Context, brandID uuid.UUID, route string) (*models.PublishedPage, error) {
+	return s.find(func(p models.PublishedPage) bool { return p.BrandID == brandID && p.Route == route })
+}
+
+func (s memPublished) GetHome(ctx context.Context, brandID uuid.UUID) (*models.PublishedPage, error) {
+	return s.find(func(p models.PublishedPage) bool { return p.BrandID == brandID && p.IsHome })
+}
+
+func (s memPublished) find(match func(models.PublishedPage) bool) (*models.PublishedPage, error) {
+	var out models.PublishedPage
+	err := s.v.do(func(d *memData) error {
+		p, ok := first(d, d.publishedPages, publishedKey, match)
+		if !ok {
+			return ErrNotFound
+		}
+		out = p
+		out.Snapshot = copySnapshot(p.Snapshot)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s memPublished) List(ctx context.Context, brandID uuid.UUID) ([]models.PublishedPage, error) {
+	var published []models.PublishedPage
+	err := s.v.do(func(d *memData) error {
+		for _, p := range d.publishedPages {
+			if p.BrandID == brandID {
+				p.Snapshot = copySnapshot(p.Snapshot)
+				published = append(published, p)
+			}
+		}
+		return nil
+	})
+	sort.Slice(published, func(i, j int) bool { return published[i].Route < published[j].Route })
+	return published, err
+}
+
+func (s memPublished) Delete(ctx context.Context, pageID uuid.UUID) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.publishedPages[pageID]; !ok {
+			return ErrNotFound
+		}
+		delete(d.publishedPages, pageID)
+		return nil
+	})
+}
+
+type memRevisions struct{ v memView }
+
+func (s memRevisions) Create(ctx context.Context, revision *models.PageRevision) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.pages[revision.PageID]; !ok {
+			return ErrNotFound
+		}
+		for _, r := range d.revisions {
+			if r.PageID == revision.PageID && r.Number == revision.Number {
+				return ErrConflict
+			}
+		}
+		d.track(&revision.ID)
+		if revision.CreatedAt.IsZero() {
+			revision.CreatedAt = time.Now()
+		}
+		r := *revision
+		r.Snapshot = copySnapshot(r.Snapshot)
+		d.revisions[r.ID] = r
+		return nil
+	})
+}
+
+func (s memRevisions) LatestNumber(ctx context.Context, pageID uuid.UUID) (int, error) {
+	var last int
+	err := s.v.do(func(d *memData) error {
+		for _, r := range d.revisions {
+			if r.PageID == pageID && r.Number > last {
+				last = r.Number
+			}
+		}
+		return nil
+	})
+	return last, err
+}
+
+func (s memRevisions) List(ctx context.Context, pageID uuid.UUID) ([]models.PageRevision, error) {
+	var revisions []models.PageRevision
+	err := s.v.do(func(d *memData) error {
+		for _, r := range d.revisions {
+			if r.PageID == pageID {
+				r.Snapshot = models.PageSnapshot{}
+				revisions = append(revisions, r)
+			}
+		}
+		return nil
+	})
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Number > revisions[j].Number })
+	return revisions, err
+}
+
+func (s memRevisions) Get(ctx context.Context, pageID uuid.UUID, number int) (*models.PageRevision, error) {
+	var out models.PageRevision
+	err := s.v.do(func(d *memData) error {
+		for _, r := range d.revisions {
+			if r.PageID == pageID && r.Number == number {
+				out = r
+				out.Snapshot = copySnapshot(r.Snapshot)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type memWidgetTypes struct{ v memView }
+
+func widgetTypeKey(t models.WidgetType) uuid.UUID { return t.ID }
+
+func (s memWidgetTypes) Create(ctx context.Context, widgetType *models.WidgetType) error {
+	return s.v.do(func(d *memData) error {
+		if _, taken := first(d, d.widgetTypes, widgetTypeKey, func(t models.WidgetType) bool {
+			return t.BrandID == widgetType.BrandID && t.Name == widgetType.Name
+		}); taken {
+			return ErrConflict
+		}
+		d.track(&widgetType.ID)
+		stamp(&widgetType.CreatedAt, &widgetType.UpdatedAt)
+		d.widgetTypes[widgetType.ID] = copyWidgetType(*widgetType)
+		return nil
+	})
+}
+
+func (s memWidgetTypes) Get(ctx context.Context, brandID, id uuid.UUID) (*models.WidgetType, error) {
+	return s.find(func(t models.WidgetType) bool { return t.ID == id && t.BrandID == brandID })
+}
+
+func (s memWidgetTypes) GetByName(ctx context.Context, brandID uuid.UUID, name string) (*models.WidgetType, error) {
+	return s.find(func(t models.WidgetType) bool { return t.BrandID == brandID && t.Name == name })
+}
+
+func (s memWidgetTypes) find(match func(models.WidgetType) bool) (*models.WidgetType, error) {
+	var out models.WidgetType
+	err := s.v.do(func(d *memData) error {
+		t, ok := first(d, d.widgetTypes, widgetTypeKey, match)
+		if !ok {
+			return ErrNotFound
+		}
+		out = copyWidgetType(t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s memWidgetTypes) List(ctx context.Context, brandID uuid.UUID) ([]models.WidgetType, error) {
+	var widgetTypes []models.WidgetType
+	err := s.v.do(func(d *memData) error {
+		for _, t := range d.widgetTypes {
+			if t.BrandID == brandID {
+				widgetTypes = append(widgetTypes, copyWidgetType(t))
+			}
+		}
+		return nil
+	})
+	sort.Slice(widgetTypes, func(i, j int) bool { return widgetTypes[i].Name < widgetTypes[j].Name })
+	return widgetTypes, err
+}
+
+func (s memWidgetTypes) Update(ctx context.Context, widgetType *models.WidgetType) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.widgetTypes[widgetType.ID]; !ok {
+			return ErrNotFound
+		}
+		if _, taken := first(d, d.widgetTypes, widgetTypeKey, func(t models.WidgetType) bool {
+			return t.ID != widgetType.ID && t.BrandID == widgetType.BrandID && t.Name == widgetType.Name
+		}); taken {
+			return ErrConflict
+		}
+		stamp(&widgetType.CreatedAt, &widgetType.UpdatedAt)
+		d.widgetTypes[widgetType.ID] = copyWidgetType(*widgetType)
+		return nil
+	})
+}
+
+func (s memWidgetTypes) Delete(ctx context.Context, brandID, id uuid.UUID) error {
+	return s.v.do(func(d *memData) error {
+		if t, ok := d.widgetTypes[id]; !ok || t.BrandID != brandID {
+			return ErrNotFound
+		}
+		delete(d.widgetTypes, id)
+		return nil
+	})
+}
+
+type memPageTemplates struct{ v memView }
+
+func pageTemplateKey(t models.PageTemplate) uuid.UUID { return t.ID }
+
+func copyPageTemplate(t models.PageTemplate) models.PageTemplate {
+	if t.Widgets != nil {
+		widgets := make([]models.TemplateWidget, len(t.Widgets))
+		for i, w := range t.Widgets {
+			widgets[i] = models.TemplateWidget{Type: w.Type, Config: copyConfig(w.Config)}
+		}
+		t.Widgets = widgets
+	}
+	return t
+}
+
+// nameTaken reports whether another of the brand's templates already uses template's name.
+func (s memPageTemplates) nameTaken(d *memData, template *models.PageTemplate) bool {
+	_, taken := first(d, d.pageTemplates, pageTemplateKey, func(t models.PageTemplate) bool {
+		return t.ID != template.ID && t.BrandID == template.BrandID && t.Name == template.Name
+	})
+	return taken
+}
+
+func (s memPageTemplates) Create(ctx context.Context, template *models.PageTemplate) error {
+	return s.v.do(func(d *memData) error {
+		if s.nameTaken(d, template) {
+			return ErrConflict
+		}
+		d.track(&template.ID)
+		stamp(&template.CreatedAt, &template.UpdatedAt)
+		d.pageTemplates[template.ID] = copyPageTemplate(*template)
+		return nil
+	})
+}
+
+func (s memPageTemplates) Get(ctx context.Context, brandID, id uuid.UUID) (*models.PageTemplate, error) {
+	var out models.PageTemplate
+	err := s.v.do(func(d *memData) error {
+		t, ok := d.pageTemplates[id]
+		if !ok || t.BrandID != brandID {
+			return ErrNotFound
+		}
+		out = copyPageTemplate(t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s memPageTemplates) List(ctx context.Context, brandID uuid.UUID) ([]models.PageTemplate, error) {
+	var templates []models.PageTemplate
+	err := s.v.do(func(d *memData) error {
+		for _, t := range d.pageTemplates {
+			if t.BrandID == brandID {
+				templates = append(templates, copyPageTemplate(t))
+			}
+		}
+		return nil
+	})
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, err
+}
+
+func (s memPageTemplates) Update(ctx context.Context, template *models.PageTemplate) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.pageTemplates[template.ID]; !ok {
+			return ErrNotFound
+		}
+		if s.nameTaken(d, template) {
+			return ErrConflict
+		}
+		stamp(&template.CreatedAt, &template.UpdatedAt)
+		d.pageTemplates[template.ID] = copyPageTemplate(*template)
+		return nil
+	})
+}
+
+func (s memPageTemplates) Delete(ctx context.Context, brandID, id uuid.UUID) error {
+	return s.v.do(func(d *memData) error {
+		if t, ok := d.pageTemplates[id]; !ok || t.BrandID != brandID {
+			return ErrNotFound
+		}
+		delete(d.pageTemplates, id)
+		return nil
+	})
+}
+
+type memDomains struct{ v memView }
+
+func domainKey(bd models.BrandDomain) uuid.UUID { return bd.ID }
+
+// domainClash reports whether domain would break (brand, hostname) uniqueness or the one-verified-claim rule.
+func domainClash(d *memData, domain *models.BrandDomain) bool {
+	_, taken := first(d, d.domains, domainKey, func(bd models.BrandDomain) bool {
+		if bd.ID == domain.ID || bd.Hostname != domain.Hostname {
+			return false
+		}
+		return bd.BrandID == domain.BrandID || (bd.Verified() && domain.Verified())
+	})
+	return taken
+}
+
+func (s memDomains) Create(ctx context.Context, domain *models.BrandDomain) error {
+	return s.v.do(func(d *memData) error {
+		if domainClash(d, domain) {
+			return ErrConflict
+		}
+		d.track(&domain.ID)
+		stamp(&domain.CreatedAt, &domain.UpdatedAt)
+		d.domains[domain.ID] = *domain
+		return nil
+	})
+}
+
+func (s memDomains) Get(ctx context.Context, brandID, id uuid.UUID) (*models.BrandDomain, error) {
+	return s.find(func(bd models.BrandDomain) bool { return bd.ID == id && bd.BrandID == brandID })
+}
+
+func (s memDomains) GetVerified(ctx context.Context, hostname string) (*models.BrandDomain, error) {
+	return s.find(func(bd models.BrandDomain) bool { return bd.Hostname == hostname && bd.Verified() })
+}
+
+func (s memDomains) find(match func(models.BrandDomain) bool) (*models.BrandDomain, error) {
+	var out models.BrandDomain
+	err := s.v.do(func(d *memData) error {
+		bd, ok := first(d, d.domains, domainKey, match)
+		if !ok {
+			return ErrNotFound
+		}
+		out = bd
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s memDomains) List(ctx context.Context, brandID uuid.UUID) ([]models.BrandDomain, error) {
+	var domains []models.BrandDomain
+	err := s.v.do(func(d *memData) error {
+		domains = filter(d, d.domains, domainKey, func(bd models.BrandDomain) bool { return bd.BrandID == brandID })
+		return nil
+	})
+	sort.Slice(domains, func(i, j int) bool { return domains[i].Hostname < domains[j].Hostname })
+	return domains, err
+}
+
+func (s memDomains) Update(ctx context.Context, domain *models.BrandDomain) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.domains[domain.ID]; !ok {
+			return ErrNotFound
+		}
+		if domainClash(d, domain) {
+			return ErrConflict
+		}
+		stamp(&domain.CreatedAt, &domain.UpdatedAt)
+		d.domains[domain.ID] = *domain
+		return nil
+	})
+}
+
+func (s memDomains) Delete(ctx context.Context, brandID, id uuid.UUID) error {
+	return s.v.do(func(d *memData) error {
+		if bd, ok := d.domains[id]; !ok || bd.BrandID != brandID {
+			return ErrNotFound
+		}
+		delete(d.domains, id)
+		return nil
+	})
+}
+
+type memAPIKeys struct{ v memView }
+
+func apiKeyKey(k models.APIKey) uuid.UUID { return k.ID }
+
+func copyAPIKey(k models.APIKey) models.APIKey {
+	k.Scopes = slices.Clone(k.Scopes)
+	return k
+}
+
+func (s memAPIKeys) Create(ctx context.Context, key *models.APIKey) error {
+	return s.v.do(func(d *memData) error {
+		if _, taken := first(d, d.apiKeys, apiKeyKey, func(k models.APIKey) bool { return k.Prefix == key.Prefix }); taken {
+			return ErrConflict
+		}
+		d.track(&key.ID)
+		stamp(&key.CreatedAt, &key.UpdatedAt)
+		d.apiKeys[key.ID] = copyAPIKey(*key)
+		return nil
+	})
+}
+
+func (s memAPIKeys) Get(ctx context.Context, brandID, id uuid.UUID) (*models.APIKey, error) {
+	return s.find(func(k models.APIKey) bool { return k.ID == id && k.BrandID == brandID })
+}
+
+func (s memAPIKeys) GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	return s.find(func(k models.APIKey) bool { return k.Prefix == prefix })
+}
+
+func (s memAPIKeys) find(match func(models.APIKey) bool) (*models.APIKey, error) {
+	var out models.APIKey
+	err := s.v.do(func(d *memData) error {
+		k, ok := first(d, d.apiKeys, apiKeyKey, match)
+		if !ok {
+			return ErrNotFound
+		}
+		out = copyAPIKey(k)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s memAPIKeys) List(ctx context.Context, brandID uuid.UUID) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := s.v.do(func(d *memData) error {
+		keys = filter(d, d.apiKeys, apiKeyKey, func(k models.APIKey) bool { return k.BrandID == brandID })
+		for i := range keys {
+			keys[i] = copyAPIKey(keys[i])
+		}
+		return nil
+	})
+	slices.Reverse(keys)
+	return keys, err
+}
+
+func (s memAPIKeys) Update(ctx context.Context, key *models.APIKey) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.apiKeys[key.ID]; !ok {
+			return ErrNotFound
+		}
+		if _, taken := first(d, d.apiKeys, apiKeyKey, func(k models.APIKey) bool {
+			return k.ID != key.ID && k.Prefix == key.Prefix
+		}); taken {
+			return ErrConflict
+		}
+		stamp(&key.CreatedAt, &key.UpdatedAt)
+		d.apiKeys[key.ID] = copyAPIKey(*key)
+		return nil
+	})
+}
+
+func (s memAPIKeys) Touch(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	return s.v.do(func(d *memData) error {
+		k, ok := d.apiKeys[id]
+		if !ok {
+			return ErrNotFound
+		}
+		k.LastUsedAt = &usedAt
+		d.apiKeys[id] = k
+		return nil
+	})
+}
+
+type memSessions struct{ v memView }
+
+func sessionKey(s models.Session) uuid.UUID { return s.ID }
+
+func (s memSessions) Create(ctx context.Context, session *models.Session) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.users[session.UserID]; !ok {
+			return ErrNotFound
+		}
+		d.track(&session.ID)
+		stamp(&session.CreatedAt, &session.UpdatedAt)
+		d.sessions[session.ID] = *session
+		return nil
+	})
+}
+
+func (s memSessions) Get(ctx context.Context, brandID, id uuid.UUID) (*models.Session, error) {
+	var out models.Session
+	err := s.v.do(func(d *memData) error {
+		session, ok := d.sessions[id]
+		if !ok || session.BrandID != brandID {
+			return ErrNotFound
+		}
+		out = session
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s memSessions) ListActive(ctx context.Context, brandID uuid.UUID, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := s.v.do(func(d *memData) error {
+		sessions = filter(d, d.sessions, sessionKey, func(s models.Session) bool { return s.BrandID == brandID && s.Active(now) })
+		return nil
+	})
+	slices.Reverse(sessions)
+	return sessions, err
+}
+
+func (s memSessions) Update(ctx context.Context, session *models.Session) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.sessions[session.ID]; !ok {
+			return ErrNotFound
+		}
+		stamp(&session.CreatedAt, &session.UpdatedAt)
+		d.sessions[session.ID] = *session
+		return nil
+	})
+}
+
+func (s memSessions) Lock(ctx context.Context, id uuid.UUID) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.sessions[id]; !ok {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (s memSessions) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	return s.v.do(func(d *memData) error {
+		now := time.Now()
+		maps.DeleteFunc(d.revokedTokens, func(_ string, exp time.Time) bool { return exp.Before(now) })
+		if _, ok := d.revokedTokens[jti]; !ok {
+			d.revokedTokens[jti] = expiresAt
+		}
+		return nil
+	})
+}
+
+func (s memSessions) TokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := s.v.do(func(d *memData) error {
+		_, revoked = d.revokedTokens[jti]
+		return nil
+	})
+	return revoked, err
+}
+
+type memUserTokens struct{ v memView }
+
+func userTokenKey(t models.UserToken) uuid.UUID { return t.ID }
+
+func (s memUserTokens) Create(ctx context.Context, token *models.UserToken) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.users[token.UserID]; !ok {
+			return ErrNotFound
+		}
+		if _, taken := first(d, d.userTokens, userTokenKey, func(t models.UserToken) bool { return t.TokenHash == token.TokenHash }); taken {
+			return ErrConflict
+		}
+		d.track(&token.ID)
+		if token.CreatedAt.IsZero() {
+			token.CreatedAt = time.Now()
+		}
+		d.userTokens[token.ID] = *token
+		return nil
+	})
+}
+
+func (s memUserTokens) GetByHash(ctx context.Context, purpose, hash string) (*models.UserToken, error) {
+	var out models.UserToken
+	err := s.v.do(func(d *memData) error {
+		t, ok := first(d, d.userTokens, userTokenKey, func(t models.UserToken) bool {
+			return t.Purpose == purpose && t.TokenHash == hash
+		})
+		if !ok {
+			return ErrNotFound
+		}
+		out = t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s memUserTokens) MarkUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	return s.v.do(func(d *memData) error {
+		t, ok := d.userTokens[id]
+		if !ok || t.UsedAt != nil {
+			return ErrNotFound
+		}
+		t.UsedAt = &usedAt
+		d.userTokens[id] = t
+		return nil
+	})
+}
+
+func (s memUserTokens) InvalidateUser(ctx context.Context, userID uuid.UUID, purpose string, usedAt time.Time) error {
+	return s.v.do(func(d *memData) error {
+		for id, t := range d.userTokens {
+			if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+				t.UsedAt = &usedAt
+				d.userTokens[id] = t
+			}
+		}
+		return nil
+	})
+}
+
+type memSSO struct{ v memView }
+
+func identityKey(i models.UserIdentity) uuid.UUID { return i.ID }
+
+func (s memSSO) GetProvider(ctx context.Context, brandID uuid.UUID) (*models.OIDCProvider, error) {
+	var out models.OIDCProvider
+	err := s.v.do(func(d *memData) error {
+		p, ok := d.oidcProviders[brandID]
+		if !ok {
+			return ErrNotFound
+		}
+		out = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s memSSO) SaveProvider(ctx context.Context, provider *models.OIDCProvider) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.brands[provider.BrandID]; !ok {
+			return ErrNotFound
+		}
+		if existing, ok := d.oidcProviders[provider.BrandID]; ok {
+			provider.CreatedAt = existing.CreatedAt
+		}
+		stamp(&provider.CreatedAt, &provider.UpdatedAt)
+		d.oidcProviders[provider.BrandID] = *provider
+		return nil
+	})
+}
+
+func (s memSSO) DeleteProvider(ctx context.Context, brandID uuid.UUID) error {
+	return s.v.do(func(d *memData) error {
+		if _, ok := d.oidcProviders[brandID]; !ok {
+			return ErrNotFound
+		}
+		delete(d.oidcProviders, brandID)
+		return nil
+	})
+}
+
+func (s memSSO) GetIdentity(ctx context.Context, brandID uuid.UUID, issuer, subject string) (*models.UserIdentity, error) {
+	var out models.UserIdentity
+	err := s.v.do(func(d *memData) error {
+		i, ok := first(d, d.identities, identityKey, func(i models.UserIdentity) bool {
+			return i.BrandID == brandID && i.Issuer == issuer && i.Subject == subject
+		})
+		if !ok {
+			return ErrNotFound
+		}
+		out = i
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s memSSO) CreateIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	return s.v.do(func(d *memData) error {
+		if u, ok := d.users[identity.UserID]; !ok || u.BrandID != identity.BrandID {
+			return ErrNotFound
+		}
+		if _, taken := first(d, d.identities, identityKey, func(i models.UserIdentity) bool {
+			return i.BrandID == identity.BrandID && i.Issuer == identity.Issuer && i.Subject == identity.Subject
+		}); taken {
+			return ErrConflict
+		}
+		d.track(&identity.ID)
+		if identity.CreatedAt.IsZero() {
+			identity.CreatedAt = time.Now()
+		}
+		d.identities[identity.ID] = *identity
+		return nil
+	})
+}
+
+type memAudit struct{ v memView }
+
+func auditEventKey(e models.AuditEvent) uuid.UUID { return e.ID }
+
+func (s memAudit) Create(ctx context.Context, event *models.AuditEvent) error {
+	return s.v.do(func(d *memData) error {
+		d.track(&event.ID)
+		if event.CreatedAt.IsZero() {
+			event.CreatedAt = time.Now()
+		}
+		stored := *event
+		stored.Changes = maps.Clone(event.Changes)
+		d.auditEvents[event.ID] = stored
+		return nil
+	})
+}
+
+func (s memAudit) matching(d *memData, brandID uuid.UUID, f AuditFilter) []models.AuditEvent {
+	events := filter(d, d.auditEvents, auditEventKey, func(e models.AuditEvent) bool {
+		return e.BrandID == brandID &&
+			(f.EntityType == "" || e.EntityType == f.EntityType) &&
+			(f.EntityID == nil || e.EntityID == *f.EntityID) &&
+			(f.ActorID == nil || e.ActorUserID != nil && *e.ActorUserID == *f.ActorID ||
+				e.ActorAPIKeyID != nil && *e.ActorAPIKeyID == *f.ActorID) &&
+			(f.Action == "" || e.Action == f.Action) &&
+			(f.Since.IsZero() || !e.CreatedAt.Before(f.Since)) &&
+			(f.Until.IsZero() || e.CreatedAt.Before(f.Until))
+	})
+	slices.Reverse(events)
+	return events
+}
+
+func (s memAudit) List(ctx context.Context, brandID uuid.UUID, f AuditFilter) ([]models.AuditEvent, error) {
+	var out []models.AuditEvent
+	err := s.v.do(func(d *memData) error {
+		out = s.matching(d, brandID, f)
+		if f.Limit > 0 {
+			start := min(f.Offset, len(out))
+			out = out[start:min(start+f.Limit, len(out))]
+		}
+		for i := range out {
+			out[i].Changes = maps.Clone(out[i].Changes)
+		}
+		return nil
+	})
+	return out, err
+}
+
+func (s memAudit) Count(ctx context.Context, brandID uuid.UUID, f AuditFilter) (int64, error) {
+	var n int64
+	err := s.v.do(func(d *memData) error {
+		n = int64(len(s.matching(d, brandID, f)))
+		return nil
+	})
+	return n, err
+}
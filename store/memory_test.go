@@ -0,0 +1,98 @@
+package store
+
+import (
+	"APPDROP/models"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMemoryTxRollsBack(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+	brand := models.Brand{Name: "Acme", Domain: "acme", Email: "owner@acme.com"}
+	if err := s.Brands().Create(ctx, &brand); err != nil {
+		t.Fatalf("create brand: %v", err)
+	}
+
+	boom := errors.New("boom")
+	err := s.Tx(ctx, func(tx Store) error {
+		page := models.Page{BrandID: brand.ID, Name: "Home", Route: "/"}
+		if err := tx.Pages().Create(ctx, &page); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("Tx: got %v, want %v", err, boom)
+	}
+	if n, _ := s.Pages().Count(ctx, brand.ID); n != 0 {
+		t.Errorf("page count after rollback: got %d, want 0", n)
+	}
+}
+
+func TestMemoryUniqueness(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+	brandID := uuid.New()
+	if err := s.Brands().Create(ctx, &models.Brand{ID: brandID, Domain: "acme", Email: "a@acme.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Brands().Create(ctx, &models.Brand{Domain: "acme", Email: "b@acme.com"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("duplicate domain: got %v, want ErrConflict", err)
+	}
+
+	first := models.Page{BrandID: brandID, Name: "About", Route: "/about"}
+	if err := s.Pages().Create(ctx, &first); err != nil {
+		t.Fatal(err)
+	}
+	second := models.Page{BrandID: brandID, Name: "Other", Route: "/other"}
+	if err := s.Pages().Create(ctx, &second); err != nil {
+		t.Fatal(err)
+	}
+	second.Route = "/about"
+	if err := s.Pages().Update(ctx, &second); !errors.Is(err, ErrConflict) {
+		t.Errorf("route clash on update: got %v, want ErrConflict", err)
+	}
+}
+
+func TestMemoryPageDeleteCascades(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+	brandID := uuid.New()
+	page := models.Page{BrandID: brandID, Name: "Sale", Route: "/sale"}
+	if err := s.Pages().Create(ctx, &page); err != nil {
+		t.Fatal(err)
+	}
+	for i, typ := range []string{"text", "banner", "text"} {
+		w := models.Widget{PageID: page.ID, Type: typ, Position: 2 - i, Config: map[string]interface{}{"n": i}}
+		if err := s.Widgets().Create(ctx, &w); err != nil {
+			t.Fatal(err)
+		}
+	}
+	widgets, err := s.Widgets().ListByPage(ctx, page.ID, "text")
+	if err != nil || len(widgets) != 2 || widgets[0].Position != 0 {
+		t.Fatalf("ListByPage(text): got %+v, %v", widgets, err)
+	}
+	// Mutating a returned record must not leak into the store.
+	widgets[0].Config["n"] = "changed"
+	again, _ := s.Widgets().Get(ctx, widgets[0].ID)
+	if again.Config["n"] == "changed" {
+		t.Error("stored widget config was mutated through a returned copy")
+	}
+
+	if err := s.Revisions().Create(ctx, &models.PageRevision{PageID: page.ID, BrandID: brandID, Number: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Pages().Delete(ctx, brandID, page.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := s.Widgets().CountByType(ctx, brandID, "text"); n != 0 {
+		t.Errorf("widgets left after page delete: %d", n)
+	}
+	if last, _ := s.Revisions().LatestNumber(ctx, page.ID); last != 0 {
+		t.Errorf("revisions left after page delete: latest %d", last)
+	}
+}
@@ -0,0 +1,624 @@
+package store
+
+import (
+	"APPDROP/models"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Postgres is the GORM-backed Store used in production.
+type Postgres struct {
+	db *gorm.DB
+}
+
+// NewPostgres wraps an open GORM handle (normally db.DB) whose schema is already migrated.
+func NewPostgres(gdb *gorm.DB) *Postgres {
+	return &Postgres{db: gdb}
+}
+
+func (p *Postgres) Brands() BrandStore                 { return pgBrands{p.db} }
+func (p *Postgres) Users() UserStore                   { return pgUsers{p.db} }
+func (p *Postgres) Pages() PageStore                   { return pgPages{p.db} }
+func (p *Postgres) Widgets() WidgetStore               { return pgWidgets{p.db} }
+func (p *Postgres) PublishedPages() PublishedPageStore { return pgPublished{p.db} }
+func (p *Postgres) Revisions() RevisionStore           { return pgRevisions{p.db} }
+func (p *Postgres) WidgetTypes() WidgetTypeStore       { return pgWidgetTypes{p.db} }
+func (p *Postgres) PageTemplates() PageTemplateStore   { return pgPageTemplates{p.db} }
+func (p *Postgres) Domains() DomainStore               { return pgDomains{p.db} }
+func (p *Postgres) APIKeys() APIKeyStore               { return pgAPIKeys{p.db} }
+func (p *Postgres) Sessions() SessionStore             { return pgSessions{p.db} }
+func (p *Postgres) UserTokens() UserTokenStore         { return pgUserTokens{p.db} }
+func (p *Postgres) SSO() SSOStore                      { return pgSSO{p.db} }
+func (p *Postgres) Audit() AuditStore                  { return pgAudit{p.db} }
+
+func (p *Postgres) Tx(ctx context.Context, fn func(Store) error) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&Postgres{db: tx})
+	})
+}
+
+// translate maps driver errors onto the package's sentinel errors.
+func translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrConflict
+	}
+	return err
+}
+
+// deleted reports ErrNotFound when a delete matched no rows.
+func deleted(res *gorm.DB) error {
+	if res.Error != nil {
+		return translate(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type pgBrands struct{ db *gorm.DB }
+
+func (s pgBrands) Create(ctx context.Context, brand *models.Brand) error {
+	return translate(s.db.WithContext(ctx).Create(brand).Error)
+}
+
+func (s pgBrands) Get(ctx context.Context, id uuid.UUID) (*models.Brand, error) {
+	var brand models.Brand
+	if err := s.db.WithContext(ctx).First(&brand, "id = ?", id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &brand, nil
+}
+
+func (s pgBrands) GetByDomain(ctx context.Context, domain string) (*models.Brand, error) {
+	var brand models.Brand
+	if err := s.db.WithContext(ctx).Where("domain = ?", domain).First(&brand).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &brand, nil
+}
+
+func (s pgBrands) GetByEmail(ctx context.Context, email string) (*models.Brand, error) {
+	var brand models.Brand
+	if err := s.db.WithContext(ctx).Where("email = ?", email).First(&brand).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &brand, nil
+}
+
+func (s pgBrands) Update(ctx context.Context, brand *models.Brand) error {
+	return translate(s.db.WithContext(ctx).Save(brand).Error)
+}
+
+func (s pgBrands) Delete(ctx context.Context, id uuid.UUID) error {
+	// Everything brand-scoped references brands(id) with ON DELETE CASCADE.
+	return deleted(s.db.WithContext(ctx).Delete(&models.Brand{}, "id = ?", id))
+}
+
+type pgUsers struct{ db *gorm.DB }
+
+func (s pgUsers) Create(ctx context.Context, user *models.User) error {
+	return translate(s.db.WithContext(ctx).Create(user).Error)
+}
+
+func (s pgUsers) Get(ctx context.Context, brandID, id uuid.UUID) (*models.User, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("id = ? AND brand_id = ?", id, brandID).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (s pgUsers) GetByEmail(ctx context.Context, brandID uuid.UUID, email string) (*models.User, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("brand_id = ? AND email = ?", brandID, email).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (s pgUsers) List(ctx context.Context, brandID uuid.UUID) ([]models.User, error) {
+	var users []models.User
+	err := s.db.WithContext(ctx).Where("brand_id = ?", brandID).Order("created_at ASC").Find(&users).Error
+	return users, translate(err)
+}
+
+func (s pgUsers) CountByRole(ctx context.Context, brandID uuid.UUID, role string) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.User{}).Where("brand_id = ? AND role = ?", brandID, role).Count(&count).Error
+	return count, translate(err)
+}
+
+func (s pgUsers) Update(ctx context.Context, user *models.User) error {
+	return translate(s.db.WithContext(ctx).Save(user).Error)
+}
+
+func (s pgUsers) Lock(ctx context.Context, id uuid.UUID) error {
+	var user models.User
+	err := s.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, "id = ?", id).Error
+	return translate(err)
+}
+
+func (s pgUsers) Delete(ctx context.Context, brandID, id uuid.UUID) error {
+	return deleted(s.db.WithContext(ctx).Where("id = ? AND brand_id = ?", id, brandID).Delete(&models.User{}))
+}
+
+type pgPages struct{ db *gorm.DB }
+
+func (s pgPages) Create(ctx context.Context, page *models.Page) error {
+	return translate(s.db.WithContext(ctx).Omit("Widgets").Create(page).Error)
+}
+
+func (s pgPages) Get(ctx context.Context, brandID, id uuid.UUID) (*models.Page, error) {
+	var page models.Page
+	if err := s.db.WithContext(ctx).Where("id = ? AND brand_id = ?", id, brandID).First(&page).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &page, nil
+}
+
+func (s pgPages) GetByRoute(ctx context.Context, brandID uuid.UUID, route string) (*models.Page, error) {
+	var page models.Page
+	if err := s.db.WithContext(ctx).Where("brand_id = ? AND route = ?", brandID, route).First(&page).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &page, nil
+}
+
+func (s pgPages) GetHome(ctx context.Context, brandID uuid.UUID) (*models.Page, error) {
+	var page models.Page
+	if err := s.db.WithContext(ctx).Where("brand_id = ? AND is_home = true", brandID).First(&page).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &page, nil
+}
+
+func (s pgPages) List(ctx context.Context, brandID uuid.UUID, opts PageListOptions) ([]models.Page, error) {
+	query := s.db.WithContext(ctx).Where("brand_id = ?", brandID).Order("created_at ASC")
+	if opts.Limit > 0 {
+		query = query.Offset(opts.Offset).Limit(opts.Limit)
+	}
+	var pages []models.Page
+	err := query.Find(&pages).Error
+	return pages, translate(err)
+}
+
+func (s pgPages) Count(ctx context.Context, brandID uuid.UUID) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.Page{}).Where("brand_id = ?", brandID).Count(&count).Error
+	return count, translate(err)
+}
+
+func (s pgPages) Update(ctx context.Context, page *models.Page) error {
+	return translate(s.db.WithContext(ctx).Omit("Widgets").Save(page).Error)
+}
+
+func (s pgPages) Delete(ctx context.Context, brandID, id uuid.UUID) error {
+	// Widgets, published snapshots and revisions are removed by ON DELETE CASCADE.
+	return deleted(s.db.WithContext(ctx).Where("id = ? AND brand_id = ?", id, brandID).Delete(&models.Page{}))
+}
+
+func (s pgPages) Lock(ctx context.Context, id uuid.UUID) error {
+	var page models.Page
+	err := s.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&page, "id = ?", id).Error
+	return translate(err)
+}
+
+type pgWidgets struct{ db *gorm.DB }
+
+func (s pgWidgets) Create(ctx context.Context, widget *models.Widget) error {
+	return translate(s.db.WithContext(ctx).Create(widget).Error)
+}
+
+func (s pgWidgets) Get(ctx context.Context, id uuid.UUID) (*models.Widget, error) {
+	var widget models.Widget
+	if err := s.db.WithContext(ctx).First(&widget, "id = ?", id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &widget, nil
+}
+
+func (s pgWidgets) ListByPage(ctx context.Context, pageID uuid.UUID, widgetType string) ([]models.Widget, error) {
+	query := s.db.WithContext(ctx).Where("page_id = ?", pageID)
+	if widgetType != "" {
+		query = query.Where("type = ?", widgetType)
+	}
+	var widgets []models.Widget
+	err := query.Order("position ASC").Order("created_at ASC").Find(&widgets).Error
+	return widgets, translate(err)
+}
+
+func (s pgWidgets) Update(ctx context.Context, widget *models.Widget) error {
+	return translate(s.db.WithContext(ctx).Save(widget).Error)
+}
+
+func (s pgWidgets) SetPosition(ctx context.Context, id uuid.UUID, position int) error {
+	res := s.db.WithContext(ctx).Model(&models.Widget{}).Where("id = ?", id).Update("position", position)
+	return deleted(res)
+}
+
+func (s pgWidgets) Delete(ctx context.Context, id uuid.UUID) error {
+	return deleted(s.db.WithContext(ctx).Delete(&models.Widget{}, "id = ?", id))
+}
+
+func (s pgWidgets) DeleteByPage(ctx context.Context, pageID uuid.UUID) error {
+	return translate(s.db.WithContext(ctx).Delete(&models.Widget{}, "page_id = ?", pageID).Error)
+}
+
+func (s pgWidgets) CountByType(ctx context.Context, brandID uuid.UUID, widgetType string) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.Widget{}).
+		Joins("JOIN pages ON pages.id = widgets.page_id").
+		Where("pages.brand_id = ? AND widgets.type = ?", brandID, widgetType).
+		Count(&count).Error
+	return count, translate(err)
+}
+
+type pgPublished struct{ db *gorm.DB }
+
+func (s pgPublished) Save(ctx context.Context, published *models.PublishedPage) error {
+	return translate(s.db.WithContext(ctx).Save(published).Error)
+}
+
+func (s pgPublished) Get(ctx context.Context, brandID, pageID uuid.UUID) (*models.PublishedPage, error) {
+	var published models.PublishedPage
+	if err := s.db.WithContext(ctx).Where("page_id = ? AND brand_id = ?", pageID, brandID).First(&published).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &published, nil
+}
+
+func (s pgPublished) GetByRoute(ctx context.Context, brandID uuid.UUID, route string) (*models.PublishedPage, error) {
+	var published models.PublishedPage
+	if err := s.db.WithContext(ctx).Where("brand_id = ? AND route = ?", brandID, route).First(&published).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &published, nil
+}
+
+func (s pgPublished) GetHome(ctx context.Context, brandID uuid.UUID) (*models.PublishedPage, error) {
+	var published models.PublishedPage
+	if err := s.db.WithContext(ctx).Where("brand_id = ? AND is_home = true", brandID).First(&published).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &published, nil
+}
+
+func (s pgPublished) List(ctx context.Context, brandID uuid.UUID) ([]models.PublishedPage, error) {
+	var published []models.PublishedPage
+	err := s.db.WithContext(ctx).Where("brand_id = ?", brandID).Order("route ASC").Find(&published).Error
+	return published, translate(err)
+}
+
+func (s pgPublished) Delete(ctx context.Context, pageID uuid.UUID) error {
+	return deleted(s.db.WithContext(ctx).Delete(&models.PublishedPage{}, "page_id = ?", pageID))
+}
+
+type pgRevisions struct{ db *gorm.DB }
+
+func (s pgRevisions) Create(ctx context.Context, revision *models.PageRevision) error {
+	return translate(s.db.WithContext(ctx).Create(revision).Error)
+}
+
+func (s pgRevisions) LatestNumber(ctx context.Context, pageID uuid.UUID) (int, error) {
+	var last int
+	err := s.db.WithContext(ctx).Model(&models.PageRevision{}).Where("page_id = ?", pageID).
+		Select("COALESCE(MAX(number), 0)").Scan(&last).Error
+	return last, translate(err)
+}
+
+func (s pgRevisions) List(ctx context.Context, pageID uuid.UUID) ([]models.PageRevision, error) {
+	var revisions []models.PageRevision
+	err := s.db.WithContext(ctx).Omit("snapshot").Where("page_id = ?", pageID).Order("number DESC").Find(&revisions).Error
+	return revisions, translate(err)
+}
+
+func (s pgRevisions) Get(ctx context.Context, pageID uuid.UUID, number int) (*models.PageRevision, error) {
+	var revision models.PageRevision
+	if err := s.db.WithContext(ctx).Where("page_id = ? AND number = ?", pageID, number).First(&revision).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &revision, nil
+}
+
+type pgWidgetTypes struct{ db *gorm.DB }
+
+func (s pgWidgetTypes) Create(ctx context.Context, widgetType *models.WidgetType) error {
+	return translate(s.db.WithContext(ctx).Create(widgetType).Error)
+}
+
+func (s pgWidgetTypes) Get(ctx context.Context, brandID, id uuid.UUID) (*models.WidgetType, error) {
+	var widgetType models.WidgetType
+	if err := s.db.WithContext(ctx).Where("id = ? AND brand_id = ?", id, brandID).First(&widgetType).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &widgetType, nil
+}
+
+func (s pgWidgetTypes) GetByName(ctx context.Context, brandID uuid.UUID, name string) (*models.WidgetType, error) {
+	var widgetType models.WidgetType
+	if err := s.db.WithContext(ctx).Where("brand_id = ? AND name = ?", brandID, name).First(&widgetType).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &widgetType, nil
+}
+
+func (s pgWidgetTypes) List(ctx context.Context, brandID uuid.UUID) ([]models.WidgetType, error) {
+	var widgetTypes []models.WidgetType
+	err := s.db.WithContext(ctx).Where("brand_id = ?", brandID).Order("name ASC").Find(&widgetTypes).Error
+	return widgetTypes, translate(err)
+}
+
+func (s pgWidgetTypes) Update(ctx context.Context, widgetType *models.WidgetType) error {
+	return translate(s.db.WithContext(ctx).Save(widgetType).Error)
+}
+
+func (s pgWidgetTypes) Delete(ctx context.Context, brandID, id uuid.UUID) error {
+	return deleted(s.db.WithContext(ctx).Where("id = ? AND brand_id = ?", id, brandID).Delete(&models.WidgetType{}))
+}
+
+type pgPageTemplates struct{ db *gorm.DB }
+
+func (s pgPageTemplates) Create(ctx context.Context, template *models.PageTemplate) error {
+	return translate(s.db.WithContext(ctx).Create(template).Error)
+}
+
+func (s pgPageTemplates) Get(ctx context.Context, brandID, id uuid.UUID) (*models.PageTemplate, error) {
+	var template models.PageTemplate
+	if err := s.db.WithContext(ctx).Where("id = ? AND brand_id = ?", id, brandID).First(&template).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &template, nil
+}
+
+func (s pgPageTemplates) List(ctx context.Context, brandID uuid.UUID) ([]models.PageTemplate, error) {
+	var templates []models.PageTemplate
+	err := s.db.WithContext(ctx).Where("brand_id = ?", brandID).Order("name ASC").Find(&templates).Error
+	return templates, translate(err)
+}
+
+func (s pgPageTemplates) Update(ctx context.Context, template *models.PageTemplate) error {
+	return translate(s.db.WithContext(ctx).Save(template).Error)
+}
+
+func (s pgPageTemplates) Delete(ctx context.Context, brandID, id uuid.UUID) error {
+	return deleted(s.db.WithContext(ctx).Where("id = ? AND brand_id = ?", id, brandID).Delete(&models.PageTemplate{}))
+}
+
+type pgDomains struct{ db *gorm.DB }
+
+func (s pgDomains) Create(ctx context.Context, domain *models.BrandDomain) error {
+	return translate(s.db.WithContext(ctx).Create(domain).Error)
+}
+
+func (s pgDomains) Get(ctx context.Context, brandID, id uuid.UUID) (*models.BrandDomain, error) {
+	var domain models.BrandDomain
+	if err := s.db.WithContext(ctx).Where("id = ? AND brand_id = ?", id, brandID).First(&domain).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &domain, nil
+}
+
+func (s pgDomains) GetVerified(ctx context.Context, hostname string) (*models.BrandDomain, error) {
+	var domain models.BrandDomain
+	if err := s.db.WithContext(ctx).Where("hostname = ? AND verified_at IS NOT NULL", hostname).First(&domain).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &domain, nil
+}
+
+func (s pgDomains) List(ctx context.Context, brandID uuid.UUID) ([]models.BrandDomain, error) {
+	var domains []models.BrandDomain
+	err := s.db.WithContext(ctx).Where("brand_id = ?", brandID).Order("hostname ASC").Find(&domains).Error
+	return domains, translate(err)
+}
+
+func (s pgDomains) Update(ctx context.Context, domain *models.BrandDomain) error {
+	return translate(s.db.WithContext(ctx).Save(domain).Error)
+}
+
+func (s pgDomains) Delete(ctx context.Context, brandID, id uuid.UUID) error {
+	return deleted(s.db.WithContext(ctx).Where("id = ? AND brand_id = ?", id, brandID).Delete(&models.BrandDomain{}))
+}
+
+type pgAPIKeys struct{ db *gorm.DB }
+
+func (s pgAPIKeys) Create(ctx context.Context, key *models.APIKey) error {
+	return translate(s.db.WithContext(ctx).Create(key).Error)
+}
+
+func (s pgAPIKeys) Get(ctx context.Context, brandID, id uuid.UUID) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.db.WithContext(ctx).Where("id = ? AND brand_id = ?", id, brandID).First(&key).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &key, nil
+}
+
+func (s pgAPIKeys) GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.db.WithContext(ctx).Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &key, nil
+}
+
+func (s pgAPIKeys) List(ctx context.Context, brandID uuid.UUID) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := s.db.WithContext(ctx).Where("brand_id = ?", brandID).Order("created_at DESC").Find(&keys).Error
+	return keys, translate(err)
+}
+
+func (s pgAPIKeys) Update(ctx context.Context, key *models.APIKey) error {
+	return translate(s.db.WithContext(ctx).Save(key).Error)
+}
+
+func (s pgAPIKeys) Touch(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	res := s.db.WithContext(ctx).Model(&models.APIKey{}).Where("id = ?", id).UpdateColumn("last_used_at", usedAt)
+	return deleted(res)
+}
+
+type pgSessions struct{ db *gorm.DB }
+
+func (s pgSessions) Create(ctx context.Context, session *models.Session) error {
+	return translate(s.db.WithContext(ctx).Create(session).Error)
+}
+
+func (s pgSessions) Get(ctx context.Context, brandID, id uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	if err := s.db.WithContext(ctx).Where("id = ? AND brand_id = ?", id, brandID).First(&session).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &session, nil
+}
+
+func (s pgSessions) ListActive(ctx context.Context, brandID uuid.UUID, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := s.db.WithContext(ctx).
+		Where("brand_id = ? AND revoked_at IS NULL AND expires_at > ?", brandID, now).
+		Order("created_at DESC").Find(&sessions).Error
+	return sessions, translate(err)
+}
+
+func (s pgSessions) Update(ctx context.Context, session *models.Session) error {
+	return translate(s.db.WithContext(ctx).Save(session).Error)
+}
+
+func (s pgSessions) Lock(ctx context.Context, id uuid.UUID) error {
+	var session models.Session
+	err := s.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&session, "id = ?", id).Error
+	return translate(err)
+}
+
+func (s pgSessions) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	db := s.db.WithContext(ctx)
+	if err := db.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error; err != nil {
+		return translate(err)
+	}
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+	return translate(err)
+}
+
+func (s pgSessions) TokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, translate(err)
+}
+
+type pgUserTokens struct{ db *gorm.DB }
+
+func (s pgUserTokens) Create(ctx context.Context, token *models.UserToken) error {
+	return translate(s.db.WithContext(ctx).Create(token).Error)
+}
+
+func (s pgUserTokens) GetByHash(ctx context.Context, purpose, hash string) (*models.UserToken, error) {
+	var token models.UserToken
+	if err := s.db.WithContext(ctx).Where("purpose = ? AND token_hash = ?", purpose, hash).First(&token).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &token, nil
+}
+
+func (s pgUserTokens) MarkUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	return deleted(s.db.WithContext(ctx).Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", id).UpdateColumn("used_at", usedAt))
+}
+
+func (s pgUserTokens) InvalidateUser(ctx context.Context, userID uuid.UUID, purpose string, usedAt time.Time) error {
+	err := s.db.WithContext(ctx).Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).UpdateColumn("used_at", usedAt).Error
+	return translate(err)
+}
+
+type pgSSO struct{ db *gorm.DB }
+
+func (s pgSSO) GetProvider(ctx context.Context, brandID uuid.UUID) (*models.OIDCProvider, error) {
+	var provider models.OIDCProvider
+	if err := s.db.WithContext(ctx).Where("brand_id = ?", brandID).First(&provider).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &provider, nil
+}
+
+func (s pgSSO) SaveProvider(ctx context.Context, provider *models.OIDCProvider) error {
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "brand_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"issuer", "client_id", "client_secret", "redirect_url", "default_role", "updated_at"}),
+	}).Create(provider).Error
+	return translate(err)
+}
+
+func (s pgSSO) DeleteProvider(ctx context.Context, brandID uuid.UUID) error {
+	return deleted(s.db.WithContext(ctx).Where("brand_id = ?", brandID).Delete(&models.OIDCProvider{}))
+}
+
+func (s pgSSO) GetIdentity(ctx context.Context, brandID uuid.UUID, issuer, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := s.db.WithContext(ctx).Where("brand_id = ? AND issuer = ? AND subject = ?", brandID, issuer, subject).First(&identity).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &identity, nil
+}
+
+func (s pgSSO) CreateIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	return translate(s.db.WithContext(ctx).Create(identity).Error)
+}
+
+type pgAudit struct{ db *gorm.DB }
+
+func (s pgAudit) Create(ctx context.Context, event *models.AuditEvent) error {
+	return translate(s.db.WithContext(ctx).Create(event).Error)
+}
+
+func (s pgAudit) where(ctx context.Context, brandID uuid.UUID, f AuditFilter) *gorm.DB {
+	query := s.db.WithContext(ctx).Model(&models.AuditEvent{}).Where("brand_id = ?", brandID)
+	if f.EntityType != "" {
+		query = query.Where("entity_type = ?", f.EntityType)
+	}
+	if f.EntityID != nil {
+		query = query.Where("entity_id = ?", *f.EntityID)
+	}
+	if f.ActorID != nil {
+		query = query.Where("(actor_user_id = ? OR actor_api_key_id = ?)", *f.ActorID, *f.ActorID)
+	}
+	if f.Action != "" {
+		query = query.Where("action = ?", f.Action)
+	}
+	if !f.Since.IsZero() {
+		query = query.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		query = query.Where("created_at < ?", f.Until)
+	}
+	return query
+}
+
+func (s pgAudit) List(ctx context.Context, brandID uuid.UUID, f AuditFilter) ([]models.AuditEvent, error) {
+	query := s.where(ctx, brandID, f).Order("created_at DESC, id DESC")
+	if f.Limit > 0 {
+		query = query.Offset(f.Offset).Limit(f.Limit)
+	}
+	var events []models.AuditEvent
+	err := query.Find(&events).Error
+	return events, translate(err)
+}
+
+func (s pgAudit) Count(ctx context.Context, brandID uuid.UUID, f AuditFilter) (int64, error) {
+	var count int64
+	err := s.where(ctx, brandID, f).Count(&count).Error
+	return count, translate(err)
+}
@@ -0,0 +1,217 @@
+// Package store defines the persistence interfaces used by handlers and middlewares,
+// with a PostgreSQL implementation and an in-memory one for tests and local runs.
+package store
+
+import (
+	"APPDROP/models"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist (or belongs to another brand).
+	ErrNotFound = errors.New("store: not found")
+	// ErrConflict is returned when a write would break a uniqueness rule.
+	ErrConflict = errors.New("store: conflict")
+)
+
+// Store groups every repository. Implementations must be safe for concurrent use.
+type Store interface {
+	Brands() BrandStore
+	Users() UserStore
+	Pages() PageStore
+	Widgets() WidgetStore
+	PublishedPages() PublishedPageStore
+	Revisions() RevisionStore
+	WidgetTypes() WidgetTypeStore
+	PageTemplates() PageTemplateStore
+	Domains() DomainStore
+	APIKeys() APIKeyStore
+	Sessions() SessionStore
+	UserTokens() UserTokenStore
+	SSO() SSOStore
+	Audit() AuditStore
+
+	// Tx runs fn against a Store whose writes all commit if fn returns nil and all roll back otherwise.
+	// Calling Tx on the Store passed to fn runs in the same transaction.
+	Tx(ctx context.Context, fn func(Store) error) error
+}
+
+type BrandStore interface {
+	Create(ctx context.Context, brand *models.Brand) error
+	Get(ctx context.Context, id uuid.UUID) (*models.Brand, error)
+	GetByDomain(ctx context.Context, domain string) (*models.Brand, error)
+	GetByEmail(ctx context.Context, email string) (*models.Brand, error)
+	Update(ctx context.Context, brand *models.Brand) error
+	// Delete removes the brand and everything it owns: users, pages, widgets, snapshots, revisions,
+	// widget types, custom domains, API keys, sessions, mailed tokens and SSO settings.
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type UserStore interface {
+	Create(ctx context.Context, user *models.User) error
+	Get(ctx context.Context, brandID, id uuid.UUID) (*models.User, error)
+	GetByEmail(ctx context.Context, brandID uuid.UUID, email string) (*models.User, error)
+	List(ctx context.Context, brandID uuid.UUID) ([]models.User, error)
+	CountByRole(ctx context.Context, brandID uuid.UUID, role string) (int64, error)
+	Update(ctx context.Context, user *models.User) error
+	// Lock serialises concurrent changes to a user until the surrounding Tx ends.
+	Lock(ctx context.Context, id uuid.UUID) error
+	// Delete removes the user along with their sessions, mailed tokens and SSO identities.
+	Delete(ctx context.Context, brandID, id uuid.UUID) error
+}
+
+// PageListOptions pages through a brand's pages ordered by creation time. Limit 0 means no limit.
+type PageListOptions struct {
+	Offset int
+	Limit  int
+}
+
+type PageStore interface {
+	Create(ctx context.Context, page *models.Page) error
+	// Get returns the page without widgets.
+	Get(ctx context.Context, brandID, id uuid.UUID) (*models.Page, error)
+	GetByRoute(ctx context.Context, brandID uuid.UUID, route string) (*models.Page, error)
+	GetHome(ctx context.Context, brandID uuid.UUID) (*models.Page, error)
+	List(ctx context.Context, brandID uuid.UUID, opts PageListOptions) ([]models.Page, error)
+	Count(ctx context.Context, brandID uuid.UUID) (int64, error)
+	Update(ctx context.Context, page *models.Page) error
+	// Delete removes the page along with its widgets, published snapshot and revisions.
+	Delete(ctx context.Context, brandID, id uuid.UUID) error
+	// Lock serialises concurrent changes to a page until the surrounding Tx ends.
+	Lock(ctx context.Context, id uuid.UUID) error
+}
+
+type WidgetStore interface {
+	Create(ctx context.Context, widget *models.Widget) error
+	Get(ctx context.Context, id uuid.UUID) (*models.Widget, error)
+	// ListByPage returns the page's widgets ordered by position, optionally only those of widgetType.
+	ListByPage(ctx context.Context, pageID uuid.UUID, widgetType string) ([]models.Widget, error)
+	Update(ctx context.Context, widget *models.Widget) error
+	SetPosition(ctx context.Context, id uuid.UUID, position int) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	DeleteByPage(ctx context.Context, pageID uuid.UUID) error
+	// CountByType counts widgets of widgetType across all of a brand's pages.
+	CountByType(ctx context.Context, brandID uuid.UUID, widgetType string) (int64, error)
+}
+
+type PublishedPageStore interface {
+	// Save inserts or replaces the published snapshot for published.PageID.
+	Save(ctx context.Context, published *models.PublishedPage) error
+	Get(ctx context.Context, brandID, pageID uuid.UUID) (*models.PublishedPage, error)
+	GetByRoute(ctx context.Context, brandID uuid.UUID, route string) (*models.PublishedPage, error)
+	GetHome(ctx context.Context, brandID uuid.UUID) (*models.PublishedPage, error)
+	// List returns the brand's published pages ordered by route.
+	List(ctx context.Context, brandID uuid.UUID) ([]models.PublishedPage, error)
+	Delete(ctx context.Context, pageID uuid.UUID) error
+}
+
+type RevisionStore interface {
+	Create(ctx context.Context, revision *models.PageRevision) error
+	// LatestNumber returns the highest revision number for the page, or 0 if it has none.
+	LatestNumber(ctx context.Context, pageID uuid.UUID) (int, error)
+	// List returns the page's revisions newest first, without snapshots.
+	List(ctx context.Context, pageID uuid.UUID) ([]models.PageRevision, error)
+	Get(ctx context.Context, pageID uuid.UUID, number int) (*models.PageRevision, error)
+}
+
+type WidgetTypeStore interface {
+	Create(ctx context.Context, widgetType *models.WidgetType) error
+	Get(ctx context.Context, brandID, id uuid.UUID) (*models.WidgetType, error)
+	GetByName(ctx context.Context, brandID uuid.UUID, name string) (*models.WidgetType, error)
+	// List returns the brand's registered types ordered by name.
+	List(ctx context.Context, brandID uuid.UUID) ([]models.WidgetType, error)
+	Update(ctx context.Context, widgetType *models.WidgetType) error
+	Delete(ctx context.Context, brandID, id uuid.UUID) error
+}
+
+// PageTemplateStore holds brand templates; global templates are built into the handlers.
+type PageTemplateStore interface {
+	Create(ctx context.Context, template *models.PageTemplate) error
+	Get(ctx context.Context, brandID, id uuid.UUID) (*models.PageTemplate, error)
+	// List returns the brand's templates ordered by name.
+	List(ctx context.Context, brandID uuid.UUID) ([]models.PageTemplate, error)
+	Update(ctx context.Context, template *models.PageTemplate) error
+	Delete(ctx context.Context, brandID, id uuid.UUID) error
+}
+
+type DomainStore interface {
+	Create(ctx context.Context, domain *models.BrandDomain) error
+	Get(ctx context.Context, brandID, id uuid.UUID) (*models.BrandDomain, error)
+	// GetVerified returns the verified claim on hostname, whichever brand holds it.
+	GetVerified(ctx context.Context, hostname string) (*models.BrandDomain, error)
+	// List returns the brand's hostnames ordered by hostname.
+	List(ctx context.Context, brandID uuid.UUID) ([]models.BrandDomain, error)
+	Update(ctx context.Context, domain *models.BrandDomain) error
+	Delete(ctx context.Context, brandID, id uuid.UUID) error
+}
+
+type APIKeyStore interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	Get(ctx context.Context, brandID, id uuid.UUID) (*models.APIKey, error)
+	// GetByPrefix returns the key with the given public prefix, whichever brand owns it.
+	GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	// List returns the brand's keys newest first, revoked ones included.
+	List(ctx context.Context, brandID uuid.UUID) ([]models.APIKey, error)
+	Update(ctx context.Context, key *models.APIKey) error
+	// Touch records that the key was used at usedAt without changing anything else.
+	Touch(ctx context.Context, id uuid.UUID, usedAt time.Time) error
+}
+
+type SessionStore interface {
+	Create(ctx context.Context, session *models.Session) error
+	Get(ctx context.Context, brandID, id uuid.UUID) (*models.Session, error)
+	// ListActive returns the brand's unrevoked, unexpired sessions, most recently created first.
+	ListActive(ctx context.Context, brandID uuid.UUID, now time.Time) ([]models.Session, error)
+	Update(ctx context.Context, session *models.Session) error
+	// Lock serialises concurrent changes to a session until the surrounding Tx ends.
+	Lock(ctx context.Context, id uuid.UUID) error
+	// RevokeToken adds an access token jti to the revocation list until expiresAt.
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	TokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+type UserTokenStore interface {
+	Create(ctx context.Context, token *models.UserToken) error
+	GetByHash(ctx context.Context, purpose, hash string) (*models.UserToken, error)
+	// MarkUsed redeems the token, returning ErrNotFound if it was already used, so a token works only once
+	// even under concurrent requests.
+	MarkUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error
+	// InvalidateUser marks all of the user's unused tokens for purpose as used.
+	InvalidateUser(ctx context.Context, userID uuid.UUID, purpose string, usedAt time.Time) error
+}
+
+// SSOStore holds each brand's OpenID Connect settings and the external identities linked to its users.
+type SSOStore interface {
+	GetProvider(ctx context.Context, brandID uuid.UUID) (*models.OIDCProvider, error)
+	// SaveProvider creates or replaces the brand's provider.
+	SaveProvider(ctx context.Context, provider *models.OIDCProvider) error
+	DeleteProvider(ctx context.Context, brandID uuid.UUID) error
+	GetIdentity(ctx context.Context, brandID uuid.UUID, issuer, subject string) (*models.UserIdentity, error)
+	// CreateIdentity returns ErrConflict if the subject is already linked to a user of the brand.
+	CreateIdentity(ctx context.Context, identity *models.UserIdentity) error
+}
+
+// AuditFilter narrows an audit log listing. Zero fields don't filter; Limit 0 means no limit.
+type AuditFilter struct {
+	EntityType string
+	EntityID   *uuid.UUID
+	// ActorID matches events by the user or the API key that made them.
+	ActorID *uuid.UUID
+	Action  string
+	Since   time.Time
+	Until   time.Time
+	Offset  int
+	Limit   int
+}
+
+// AuditStore is append-only: events can't be changed or removed, not even when their brand is deleted.
+type AuditStore interface {
+	Create(ctx context.Context, event *models.AuditEvent) error
+	// List returns matching events, newest first.
+	List(ctx context.Context, brandID uuid.UUID, filter AuditFilter) ([]models.AuditEvent, error)
+	Count(ctx context.Context, brandID uuid.UUID, filter AuditFilter) (int64, error)
+}
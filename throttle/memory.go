@@ -0,0 +1,53 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// retention bounds how long idle records are kept; it must exceed every policy's Window and MaxLockout.
+const retention = 24 * time.Hour
+
+// Memory keeps records in process. Use Postgres instead when several instances serve logins.
+type Memory struct {
+	mu      sync.Mutex
+	records map[string]Record
+	writes  int
+}
+
+func NewMemory() *Memory {
+	return &Memory{records: map[string]Record{}}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.records[key], nil
+}
+
+func (m *Memory) Update(ctx context.Context, key string, fn func(Record) Record) (Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec := fn(m.records[key])
+	m.records[key] = rec
+	if m.writes++; m.writes%1000 == 0 {
+		m.prune(time.Now())
+	}
+	return rec, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, key)
+	return nil
+}
+
+func (m *Memory) prune(now time.Time) {
+	for key, rec := range m.records {
+		if now.Sub(rec.LastFailureAt) > retention && !rec.LockedUntil.After(now) {
+			delete(m.records, key)
+		}
+	}
+}
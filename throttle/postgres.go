@@ -0,0 +1,66 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type loginAttempt struct {
+	Key           string `gorm:"primaryKey"`
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+func (loginAttempt) TableName() string { return "login_attempts" }
+
+// Postgres shares records between instances through the login_attempts table.
+type Postgres struct {
+	db *gorm.DB
+}
+
+func NewPostgres(gdb *gorm.DB) *Postgres {
+	return &Postgres{db: gdb}
+}
+
+func (p *Postgres) Get(ctx context.Context, key string) (Record, error) {
+	var row loginAttempt
+	err := p.db.WithContext(ctx).Where("key = ?", key).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Record{}, nil
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{Failures: row.Failures, LastFailureAt: row.LastFailureAt, LockedUntil: row.LockedUntil}, nil
+}
+
+// Update locks the row for the read-modify-write, creating it first if needed.
+func (p *Postgres) Update(ctx context.Context, key string, fn func(Record) Record) (Record, error) {
+	var rec Record
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Where("last_failure_at < ? AND locked_until < ?", now.Add(-retention), now).Delete(&loginAttempt{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&loginAttempt{Key: key}).Error; err != nil {
+			return err
+		}
+		var row loginAttempt
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&row).Error; err != nil {
+			return err
+		}
+		rec = fn(Record{Failures: row.Failures, LastFailureAt: row.LastFailureAt, LockedUntil: row.LockedUntil})
+		row.Failures, row.LastFailureAt, row.LockedUntil = rec.Failures, rec.LastFailureAt, rec.LockedUntil
+		return tx.Save(&row).Error
+	})
+	return rec, err
+}
+
+func (p *Postgres) Delete(ctx context.Context, key string) error {
+	return p.db.WithContext(ctx).Where("key = ?", key).Delete(&loginAttempt{}).Error
+}
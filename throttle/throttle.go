@@ -0,0 +1,115 @@
+// Package throttle slows down password guessing. Failed logins are counted per client IP and per
+// account; once a key reaches its policy's threshold it is locked out for a period that doubles with
+// every further failure.
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// Policy decides when a key is locked out and for how long.
+type Policy struct {
+	// Threshold is the number of failures allowed before the first lockout.
+	Threshold int
+	// BaseLockout is the first lockout; each failure after that doubles it, up to MaxLockout.
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	// Window forgets failures once this long has passed since the last one.
+	Window time.Duration
+}
+
+var (
+	// DefaultAccountPolicy protects a single login from a slow, distributed guess.
+	DefaultAccountPolicy = Policy{Threshold: 5, BaseLockout: 30 * time.Second, MaxLockout: 15 * time.Minute, Window: 15 * time.Minute}
+	// DefaultIPPolicy is looser, since several people may share an address.
+	DefaultIPPolicy = Policy{Threshold: 20, BaseLockout: 30 * time.Second, MaxLockout: 15 * time.Minute, Window: 15 * time.Minute}
+)
+
+// Record is the failure history of one key.
+type Record struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// fail returns rec after one more failure at now.
+func (p Policy) fail(rec Record, now time.Time) Record {
+	if now.Sub(rec.LastFailureAt) > p.Window && !rec.LockedUntil.After(now) {
+		rec.Failures = 0
+	}
+	rec.Failures++
+	rec.LastFailureAt = now
+	if over := rec.Failures - p.Threshold; over >= 0 {
+		lockout := p.MaxLockout
+		if over < 30 && p.BaseLockout<<over < p.MaxLockout {
+			lockout = p.BaseLockout << over
+		}
+		rec.LockedUntil = now.Add(lockout)
+	}
+	return rec
+}
+
+// Store keeps records. Update must apply fn atomically, so concurrent failures are all counted.
+type Store interface {
+	Get(ctx context.Context, key string) (Record, error)
+	Update(ctx context.Context, key string, fn func(Record) Record) (Record, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Limiter applies the IP and account policies to login attempts.
+type Limiter struct {
+	store   Store
+	IP      Policy
+	Account Policy
+	now     func() time.Time
+}
+
+func NewLimiter(s Store) *Limiter {
+	return &Limiter{store: s, IP: DefaultIPPolicy, Account: DefaultAccountPolicy, now: time.Now}
+}
+
+func ipKey(ip string) string           { return "ip:" + ip }
+func accountKey(account string) string { return "account:" + account }
+
+// Check returns how long the caller must wait before trying again, or 0 if neither key is locked out.
+// A nil Limiter allows everything.
+func (l *Limiter) Check(ctx context.Context, ip, account string) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+	now := l.now()
+	var wait time.Duration
+	for _, key := range []string{ipKey(ip), accountKey(account)} {
+		rec, err := l.store.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if d := rec.LockedUntil.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// Failure counts a failed attempt against both keys.
+func (l *Limiter) Failure(ctx context.Context, ip, account string) error {
+	if l == nil {
+		return nil
+	}
+	now := l.now()
+	if _, err := l.store.Update(ctx, ipKey(ip), func(r Record) Record { return l.IP.fail(r, now) }); err != nil {
+		return err
+	}
+	_, err := l.store.Update(ctx, accountKey(account), func(r Record) Record { return l.Account.fail(r, now) })
+	return err
+}
+
+// Success clears the account's failures. The IP's are left to expire, so logging into one account
+// can't be used to keep guessing at others.
+func (l *Limiter) Success(ctx context.Context, account string) error {
+	if l == nil {
+		return nil
+	}
+	return l.store.Delete(ctx, accountKey(account))
+}
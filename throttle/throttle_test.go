@@ -0,0 +1,46 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiterLocksOutWithBackoff(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	l := NewLimiter(NewMemory())
+	l.now = func() time.Time { return now }
+	l.Account = Policy{Threshold: 3, BaseLockout: time.Minute, MaxLockout: 3 * time.Minute, Window: time.Hour}
+
+	for i := 0; i < 2; i++ {
+		l.Failure(ctx, "1.2.3.4", "acme:a@acme.com")
+	}
+	if wait, _ := l.Check(ctx, "1.2.3.4", "acme:a@acme.com"); wait != 0 {
+		t.Fatalf("below threshold: got wait %v", wait)
+	}
+	l.Failure(ctx, "1.2.3.4", "acme:a@acme.com")
+	if wait, _ := l.Check(ctx, "5.6.7.8", "acme:a@acme.com"); wait != time.Minute {
+		t.Errorf("first lockout from another IP: got %v, want 1m", wait)
+	}
+	l.Failure(ctx, "1.2.3.4", "acme:a@acme.com")
+	if wait, _ := l.Check(ctx, "1.2.3.4", "acme:a@acme.com"); wait != 2*time.Minute {
+		t.Errorf("second lockout: got %v, want 2m", wait)
+	}
+	l.Failure(ctx, "1.2.3.4", "acme:a@acme.com")
+	l.Failure(ctx, "1.2.3.4", "acme:a@acme.com")
+	if wait, _ := l.Check(ctx, "1.2.3.4", "acme:a@acme.com"); wait != 3*time.Minute {
+		t.Errorf("capped lockout: got %v, want 3m", wait)
+	}
+
+	l.Success(ctx, "acme:a@acme.com")
+	if wait, _ := l.Check(ctx, "1.2.3.4", "acme:a@acme.com"); wait != 0 {
+		t.Errorf("after success: got wait %v", wait)
+	}
+
+	now = now.Add(2 * time.Hour)
+	l.Failure(ctx, "1.2.3.4", "acme:b@acme.com")
+	if rec, _ := l.store.Get(ctx, ipKey("1.2.3.4")); rec.Failures != 1 {
+		t.Errorf("failures outside the window were not forgotten: got %d", rec.Failures)
+	}
+}